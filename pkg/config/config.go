@@ -4,30 +4,50 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // JWTConfig структура для хранения конфигурации JWT
 type JWTConfig struct {
 	SecretKey string
+	Issuer    string // Значение iss в выдаваемых токенах, проверяется при валидации
+	Audience  string // Значение aud в выдаваемых токенах, проверяется при валидации
 }
 
-// GetEnv получает значение переменной окружения или использует значение по умолчанию, если переменная не определена
+// GetEnv получает значение переменной окружения или использует значение по умолчанию, если переменная не определена.
+// Значения по умолчанию секретных переменных (см. IsSecret) в лог не пишутся
 func GetEnv(key string, defaultValue string) string {
 	value, exists := os.LookupEnv(key)
 	if !exists {
-		log.Printf("Переменная окружения %s не установлена, используется значение по умолчанию: %s", key, defaultValue)
+		log.Printf("Переменная окружения %s не установлена, используется значение по умолчанию: %s", key, redact(key, defaultValue))
 		return defaultValue
 	}
 	return value
 }
 
+// IsSecret сообщает, содержит ли переменная окружения секрет: пароль, секретный ключ или токен
+func IsSecret(key string) bool {
+	key = strings.ToUpper(key)
+	return strings.Contains(key, "PASSWORD") || strings.Contains(key, "SECRET") || strings.Contains(key, "TOKEN")
+}
+
+// redact скрывает значение секретной переменной для вывода в лог
+func redact(key, value string) string {
+	if value != "" && IsSecret(key) {
+		return "[скрыто]"
+	}
+	return value
+}
+
 // DBConfig структура для хранения конфигурации подключения к базе данных
 type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	DBName      string
+	AutoMigrate bool // Применять миграции схемы при подключении через database.Default
 }
 
 // func init() {
@@ -37,24 +57,73 @@ type DBConfig struct {
 // 	}
 // }
 
-// LoadDBConfig загружает конфигурацию для базы данных из переменных окружения
+// LoadDBConfig загружает конфигурацию для базы данных из переменных окружения.
+// Для отсутствующих переменных используются значения по умолчанию, см. LoadDBConfigStrict
 func LoadDBConfig() *DBConfig {
+	return loadDBConfig(&loader{})
+}
+
+func loadDBConfig(l *loader) *DBConfig {
 	return &DBConfig{
-		Host:     GetEnv("POSTGRESQL_HOST", "localhost"),
-		Port:     GetEnv("POSTGRESQL_PORT", "5432"),
-		User:     GetEnv("POSTGRESQL_USER", "user"),
-		Password: GetEnv("POSTGRESQL_PASSWORD", "password"),
-		DBName:   GetEnv("POSTGRESQL_DBNAME", "default_db"),
+		Host:        l.get("POSTGRESQL_HOST", "localhost"),
+		Port:        l.get("POSTGRESQL_PORT", "5432"),
+		User:        l.require("POSTGRESQL_USER", "user"),
+		Password:    l.require("POSTGRESQL_PASSWORD", "password"),
+		DBName:      l.require("POSTGRESQL_DBNAME", "default_db"),
+		AutoMigrate: l.flag("POSTGRESQL_AUTO_MIGRATE", false),
 	}
 }
 
-// LoadJWTConfig загружает конфигурацию JWT из переменных окружения
+// LoadJWTConfig загружает конфигурацию JWT из переменных окружения.
+// Для отсутствующих переменных используются значения по умолчанию, см. LoadJWTConfigStrict
 func LoadJWTConfig() *JWTConfig {
+	return loadJWTConfig(&loader{})
+}
+
+func loadJWTConfig(l *loader) *JWTConfig {
+	secret := ""
+	if l.strict && keyRingConfigured() {
+		// Токены подписываются кольцом ключей, общий секрет не обязателен
+		secret = os.Getenv("JWT_SECRET_KEY")
+	} else {
+		secret = l.require("JWT_SECRET_KEY", "your_default_secret_key") // Получаем секретный ключ из переменной окружения
+	}
 	return &JWTConfig{
-		SecretKey: GetEnv("JWT_SECRET_KEY", "your_default_secret_key"), // Получаем секретный ключ из переменной окружения
+		SecretKey: secret,
+		Issuer:    l.get("JWT_ISSUER", "crmlib"),
+		Audience:  l.get("JWT_AUDIENCE", "crm"),
+	}
+}
+
+// KeyRingConfig структура для хранения конфигурации ключей подписи JWT
+type KeyRingConfig struct {
+	Dir            string            // Каталог с ключами: <kid>.pem и <kid>.secret
+	Files          map[string]string // Файлы ключей по kid из списка JWT_KEYS вида kid=путь,kid=путь
+	ActiveKeyID    string            // kid ключа, которым подписываются новые токены
+	GracePeriod    time.Duration     // Сколько удаленный ключ еще принимается при проверке токенов
+	ReloadInterval time.Duration     // Период перечитывания ключей
+}
+
+// LoadKeyRingConfig загружает конфигурацию ключей подписи JWT из переменных окружения
+func LoadKeyRingConfig() *KeyRingConfig {
+	return loadKeyRingConfig(&loader{})
+}
+
+func loadKeyRingConfig(l *loader) *KeyRingConfig {
+	return &KeyRingConfig{
+		Dir:            l.get("JWT_KEY_DIR", ""),
+		Files:          l.list("JWT_KEYS"),
+		ActiveKeyID:    l.get("JWT_ACTIVE_KID", ""),
+		GracePeriod:    l.duration("JWT_KEY_GRACE_PERIOD", 7*24*time.Hour), // Не меньше срока жизни рефреш токена
+		ReloadInterval: l.duration("JWT_KEY_RELOAD_INTERVAL", time.Minute),
 	}
 }
 
+// keyRingConfigured сообщает, заданы ли ключи подписи JWT для кольца ключей
+func keyRingConfigured() bool {
+	return os.Getenv("JWT_KEY_DIR") != "" || os.Getenv("JWT_KEYS") != ""
+}
+
 func findFile(root string, filename string) string {
 	var result string
 
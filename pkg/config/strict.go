@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MinSecretKeyLength — минимальная длина секретного ключа JWT в байтах: для HS256 не меньше 256 бит
+const MinSecretKeyLength = 32
+
+// knownDefaults — секреты из примеров и значений по умолчанию, с которыми сервис не должен запускаться
+var knownDefaults = []string{"your_default_secret_key", "password", "secret", "changeme", "postgres", "admin", "default"}
+
+var strictMode atomic.Bool
+
+// SetStrict включает строгий режим: database.Default и подпись токенов секретом из конфигурации
+// используют LoadDBConfigStrict и LoadJWTConfigStrict и не работают со значениями по умолчанию
+func SetStrict(strict bool) {
+	strictMode.Store(strict)
+}
+
+// Strict сообщает, включен ли строгий режим через SetStrict или переменную окружения CONFIG_STRICT=true
+func Strict() bool {
+	if strictMode.Load() {
+		return true
+	}
+	strict, _ := strconv.ParseBool(os.Getenv("CONFIG_STRICT"))
+	return strict
+}
+
+// VarError описывает отсутствующую или некорректную переменную окружения. Значение секрета в ошибку не попадает
+type VarError struct {
+	Var    string
+	Reason string
+}
+
+func (e VarError) Error() string {
+	return e.Var + ": " + e.Reason
+}
+
+// ValidationError перечисляет все отсутствующие и некорректные переменные окружения
+type ValidationError struct {
+	Errors []VarError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "некорректная конфигурация: " + strings.Join(msgs, "; ")
+}
+
+// LoadDBConfigStrict загружает конфигурацию базы данных без значений по умолчанию для учетных данных.
+// Возвращает *ValidationError, если POSTGRESQL_USER, POSTGRESQL_PASSWORD или POSTGRESQL_DBNAME не заданы,
+// пароль совпадает с известным значением по умолчанию или порт некорректен
+func LoadDBConfigStrict() (*DBConfig, error) {
+	l := &loader{strict: true}
+	cfg := loadDBConfig(l)
+	validateDBConfig(l, cfg)
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadJWTConfigStrict загружает конфигурацию JWT без секрета по умолчанию.
+// Возвращает *ValidationError, если JWT_SECRET_KEY не задан, совпадает с известным значением по умолчанию
+// или короче MinSecretKeyLength. Если заданы JWT_KEY_DIR или JWT_KEYS, секрет не обязателен
+func LoadJWTConfigStrict() (*JWTConfig, error) {
+	l := &loader{strict: true}
+	cfg := loadJWTConfig(l)
+	validateJWTConfig(l, cfg)
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate проверяет в строгом режиме конфигурацию базы данных, JWT и ключей подписи
+// и возвращает *ValidationError со всеми отсутствующими и некорректными переменными сразу.
+// Вызывается при запуске сервиса:
+//
+//	if err := config.Validate(); err != nil {
+//		log.Fatal(err)
+//	}
+func Validate() error {
+	l := &loader{strict: true}
+	validateDBConfig(l, loadDBConfig(l))
+	validateJWTConfig(l, loadJWTConfig(l))
+	loadKeyRingConfig(l)
+	return l.err()
+}
+
+func validateDBConfig(l *loader, cfg *DBConfig) {
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		l.fail("POSTGRESQL_PORT", "некорректный порт %q", cfg.Port)
+	}
+	if cfg.Password != "" && IsKnownDefault(cfg.Password) {
+		l.fail("POSTGRESQL_PASSWORD", "используется известное значение по умолчанию")
+	}
+}
+
+func validateJWTConfig(l *loader, cfg *JWTConfig) {
+	if cfg.SecretKey != "" {
+		if err := CheckSecret(cfg.SecretKey); err != nil {
+			l.fail("JWT_SECRET_KEY", "%v", err)
+		}
+	}
+	if cfg.Issuer == "" {
+		l.fail("JWT_ISSUER", "пустое значение")
+	}
+	if cfg.Audience == "" {
+		l.fail("JWT_AUDIENCE", "пустое значение")
+	}
+}
+
+// CheckSecret проверяет секрет ключа подписи: не известное значение по умолчанию и не короче MinSecretKeyLength
+func CheckSecret(secret string) error {
+	if IsKnownDefault(secret) {
+		return errors.New("используется известное значение по умолчанию")
+	}
+	if len(secret) < MinSecretKeyLength {
+		return fmt.Errorf("секрет короче %d байт", MinSecretKeyLength)
+	}
+	return nil
+}
+
+// IsKnownDefault сообщает, что значение совпадает с секретом из примеров или значением по умолчанию
+func IsKnownDefault(value string) bool {
+	for _, known := range knownDefaults {
+		if strings.EqualFold(value, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// loader читает переменные окружения. В строгом режиме обязательные переменные не получают значений
+// по умолчанию, а ошибки собираются, чтобы вернуть их все сразу
+type loader struct {
+	strict bool
+	errs   []VarError
+}
+
+// get возвращает значение необязательной переменной или значение по умолчанию
+func (l *loader) get(key, defaultValue string) string {
+	return GetEnv(key, defaultValue)
+}
+
+// require возвращает значение обязательной переменной. Значение по умолчанию используется только в обычном режиме
+func (l *loader) require(key, defaultValue string) string {
+	if !l.strict {
+		return GetEnv(key, defaultValue)
+	}
+	value := os.Getenv(key)
+	if strings.TrimSpace(value) == "" {
+		l.fail(key, "не задана")
+	}
+	return value
+}
+
+// duration возвращает длительность из переменной окружения, например 90s или 168h
+func (l *loader) duration(key string, defaultValue time.Duration) time.Duration {
+	value := GetEnv(key, defaultValue.String())
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		if l.strict {
+			l.fail(key, "некорректная длительность %q", value)
+		} else {
+			log.Printf("Некорректное значение переменной окружения %s: %q, используется значение по умолчанию: %s", key, value, defaultValue)
+		}
+		return defaultValue
+	}
+	return d
+}
+
+// flag возвращает логическое значение из переменной окружения, например true или 1
+func (l *loader) flag(key string, defaultValue bool) bool {
+	value := GetEnv(key, strconv.FormatBool(defaultValue))
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		if l.strict {
+			l.fail(key, "некорректное логическое значение %q", value)
+		} else {
+			log.Printf("Некорректное значение переменной окружения %s: %q, используется значение по умолчанию: %t", key, value, defaultValue)
+		}
+		return defaultValue
+	}
+	return b
+}
+
+// list разбирает переменную со списком вида key=value,key=value. Элементы без = пропускаются
+func (l *loader) list(key string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range strings.Split(GetEnv(key, ""), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			if l.strict {
+				l.fail(key, "некорректный элемент списка %q, ожидается ключ=значение", item)
+			} else {
+				log.Printf("Некорректный элемент списка %s %q пропущен, ожидается ключ=значение", key, item)
+			}
+			continue
+		}
+		result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+func (l *loader) fail(key, format string, args ...interface{}) {
+	l.errs = append(l.errs, VarError{Var: key, Reason: fmt.Sprintf(format, args...)})
+}
+
+// err возвращает *ValidationError со всеми собранными ошибками или nil
+func (l *loader) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: l.errs}
+}
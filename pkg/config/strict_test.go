@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// validEnv — конфигурация, которую принимает строгий режим
+var validEnv = map[string]string{
+	"POSTGRESQL_HOST":         "db",
+	"POSTGRESQL_PORT":         "5432",
+	"POSTGRESQL_USER":         "crm",
+	"POSTGRESQL_PASSWORD":     "a-strong-database-password",
+	"POSTGRESQL_DBNAME":       "crm",
+	"POSTGRESQL_AUTO_MIGRATE": "false",
+	"JWT_SECRET_KEY":          "0123456789abcdef0123456789abcdef",
+	"JWT_ISSUER":              "crmlib",
+	"JWT_AUDIENCE":            "crm",
+	"JWT_KEY_DIR":             "",
+	"JWT_KEYS":                "",
+	"JWT_ACTIVE_KID":          "",
+	"JWT_KEY_GRACE_PERIOD":    "168h",
+	"JWT_KEY_RELOAD_INTERVAL": "1m",
+}
+
+// setEnv задает переменные validEnv с заменой значений из overrides
+func setEnv(t *testing.T, overrides map[string]string) {
+	t.Helper()
+	for key, value := range validEnv {
+		if v, ok := overrides[key]; ok {
+			value = v
+		}
+		t.Setenv(key, value)
+	}
+}
+
+// failedVars возвращает отсортированные имена переменных из *ValidationError
+func failedVars(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ожидалась *ValidationError, получено %v", err)
+	}
+	vars := make([]string, len(verr.Errors))
+	for i, e := range verr.Errors {
+		vars[i] = e.Var
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      []string // Переменные с ошибками, nil — конфигурация корректна
+	}{
+		{"корректная конфигурация", nil, nil},
+		{"значения по умолчанию", map[string]string{
+			"POSTGRESQL_USER": "", "POSTGRESQL_PASSWORD": "", "POSTGRESQL_DBNAME": "", "JWT_SECRET_KEY": "",
+		}, []string{"JWT_SECRET_KEY", "POSTGRESQL_DBNAME", "POSTGRESQL_PASSWORD", "POSTGRESQL_USER"}},
+		{"известные значения по умолчанию", map[string]string{
+			"POSTGRESQL_PASSWORD": "Password", "JWT_SECRET_KEY": "your_default_secret_key",
+		}, []string{"JWT_SECRET_KEY", "POSTGRESQL_PASSWORD"}},
+		{"короткий секрет", map[string]string{"JWT_SECRET_KEY": "short-secret"}, []string{"JWT_SECRET_KEY"}},
+		{"некорректный порт", map[string]string{"POSTGRESQL_PORT": "65536"}, []string{"POSTGRESQL_PORT"}},
+		{"некорректные длительность и флаг", map[string]string{
+			"JWT_KEY_GRACE_PERIOD": "week", "POSTGRESQL_AUTO_MIGRATE": "maybe",
+		}, []string{"JWT_KEY_GRACE_PERIOD", "POSTGRESQL_AUTO_MIGRATE"}},
+		{"кольцо ключей вместо секрета", map[string]string{
+			"JWT_SECRET_KEY": "", "JWT_KEYS": "k1=/etc/crm/k1.pem",
+		}, nil},
+		{"некорректный список ключей", map[string]string{"JWT_KEYS": "k1"}, []string{"JWT_KEYS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.overrides)
+			err := Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if got := failedVars(t, err); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ошибки в переменных %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHidesSecrets(t *testing.T) {
+	const secret = "short-secret"
+	setEnv(t, map[string]string{"JWT_SECRET_KEY": secret, "POSTGRESQL_PASSWORD": "changeme"})
+	err := Validate()
+	if err == nil {
+		t.Fatal("Validate принял некорректную конфигурацию")
+	}
+	if strings.Contains(err.Error(), secret) || strings.Contains(err.Error(), "changeme") {
+		t.Errorf("ошибка содержит значение секрета: %v", err)
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		ok     bool
+	}{
+		{"0123456789abcdef0123456789abcdef", true},
+		{"0123456789abcdef0123456789abcde", false},
+		{"", false},
+		{"CHANGEME", false},
+		{"your_default_secret_key", false},
+	}
+	for _, tt := range tests {
+		if err := CheckSecret(tt.secret); (err == nil) != tt.ok {
+			t.Errorf("CheckSecret(%q) = %v, ожидалось ok=%v", tt.secret, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,1273 @@
+// Package conformance содержит общий набор проверок поведения репозиториев.
+// Набор запускается против любой реализации database.Repos (PostgreSQL и memstore),
+// чтобы гарантировать одинаковое поведение бэкендов:
+//
+//	func TestMemstore(t *testing.T) {
+//		conformance.Run(t, func(t *testing.T) database.Repos { return memstore.New() })
+//	}
+package conformance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/Maden-in-haven/crmlib/pkg/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hireDate — дата приема на работу менеджеров, создаваемых в проверках
+var hireDate = model.Date{Year: 2024, Month: time.March, Day: 1}
+
+// Factory создает репозиторий для отдельной проверки.
+// Для PostgreSQL допускается общая база: имена пользователей в проверках уникальны
+type Factory func(t *testing.T) database.Repos
+
+// Run запускает все проверки против репозиториев, созданных newRepos
+func Run(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndGetAdmin", func(t *testing.T) { testCreateAndGetAdmin(t, newRepos(t)) })
+	t.Run("CreateAndGetClient", func(t *testing.T) { testCreateAndGetClient(t, newRepos(t)) })
+	t.Run("CreateAndGetManager", func(t *testing.T) { testCreateAndGetManager(t, newRepos(t)) })
+	t.Run("UsernameUnique", func(t *testing.T) { testUsernameUnique(t, newRepos(t)) })
+	t.Run("SoftDelete", func(t *testing.T) { testSoftDelete(t, newRepos(t)) })
+	t.Run("DeleteWrongRole", func(t *testing.T) { testDeleteWrongRole(t, newRepos(t)) })
+	t.Run("Validation", func(t *testing.T) { testValidation(t, newRepos(t)) })
+	t.Run("PasswordPolicy", func(t *testing.T) { testPasswordPolicy(t, newRepos(t)) })
+	t.Run("ChangePassword", func(t *testing.T) { testChangePassword(t, newRepos(t)) })
+	t.Run("ResetPassword", func(t *testing.T) { testResetPassword(t, newRepos(t)) })
+	t.Run("UpdateUser", func(t *testing.T) { testUpdateUser(t, newRepos(t)) })
+	t.Run("UpdatePasswordHash", func(t *testing.T) { testUpdatePasswordHash(t, newRepos(t)) })
+	t.Run("LoginLockout", func(t *testing.T) { testLoginLockout(t, newRepos(t)) })
+	t.Run("MFARepository", func(t *testing.T) { testMFARepository(t, newRepos(t)) })
+	t.Run("RefreshTokens", func(t *testing.T) { testRefreshTokens(t, newRepos(t)) })
+	t.Run("TokenRevocation", func(t *testing.T) { testTokenRevocation(t, newRepos(t)) })
+	t.Run("UpdateAdminPermissions", func(t *testing.T) { testUpdateAdminPermissions(t, newRepos(t)) })
+	t.Run("UpdateClient", func(t *testing.T) { testUpdateClient(t, newRepos(t)) })
+	t.Run("UpdateManager", func(t *testing.T) { testUpdateManager(t, newRepos(t)) })
+	t.Run("RestoreUser", func(t *testing.T) { testRestoreUser(t, newRepos(t)) })
+	t.Run("PurgeUser", func(t *testing.T) { testPurgeUser(t, newRepos(t)) })
+	t.Run("PurgeDeletedOlderThan", func(t *testing.T) { testPurgeDeletedOlderThan(t, newRepos(t)) })
+	t.Run("ListPagination", func(t *testing.T) { testListPagination(t, newRepos(t)) })
+	t.Run("ListFilters", func(t *testing.T) { testListFilters(t, newRepos(t)) })
+	t.Run("SearchClients", func(t *testing.T) { testSearchClients(t, newRepos(t)) })
+	t.Run("SearchManagers", func(t *testing.T) { testSearchManagers(t, newRepos(t)) })
+	t.Run("WithTx", func(t *testing.T) { testWithTx(t, newRepos(t)) })
+	t.Run("LogAction", func(t *testing.T) { testLogAction(t, newRepos(t)) })
+	t.Run("InvalidID", func(t *testing.T) { testInvalidID(t, newRepos(t)) })
+}
+
+// uniqueName возвращает имя пользователя, не пересекающееся с другими запусками
+func uniqueName(prefix string) string {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return prefix + "_" + hex.EncodeToString(b[:])
+}
+
+func testCreateAndGetAdmin(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("admin")
+
+	id, err := r.CreateAdmin(ctx, username, "secret-password", map[string]interface{}{"users": true})
+	if err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+
+	admin, err := r.GetAdminByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAdminByID: %v", err)
+	}
+	if admin.ID != id || admin.Username != username {
+		t.Errorf("GetAdminByID = %+v, ожидались ID %s и имя %s", admin, id, username)
+	}
+	if admin.Permissions["users"] != true {
+		t.Errorf("Permissions = %v, ожидалось users=true", admin.Permissions)
+	}
+	if admin.CreatedAt.IsZero() || admin.UpdatedAt.IsZero() {
+		t.Errorf("временные метки не заполнены: %+v", admin)
+	}
+
+	user, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.Role != "admin" {
+		t.Errorf("Role = %q, ожидалось admin", user.Role)
+	}
+	if err := util.CheckPassword(user.PasswordHash, "secret-password"); err != nil {
+		t.Errorf("хеш пароля не соответствует паролю: %v", err)
+	}
+}
+
+func testCreateAndGetClient(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("client")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Иван Иванов", "+79991234567")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	client, err := r.GetClientByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetClientByID: %v", err)
+	}
+	if client.Username != username || client.FullName != "Иван Иванов" || client.PhoneNumber != "+79991234567" {
+		t.Errorf("GetClientByID = %+v", client)
+	}
+
+	user, err := r.GetUserByUsername(ctx, username)
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if user.ID != id || user.Role != "client" {
+		t.Errorf("GetUserByUsername = %+v, ожидался клиент с ID %s", user, id)
+	}
+
+	if _, err := r.GetManagerByID(ctx, id); err == nil {
+		t.Error("GetManagerByID вернул клиента как менеджера")
+	}
+}
+
+func testCreateAndGetManager(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("manager")
+
+	id, err := r.CreateManager(ctx, username, "secret-password", "Петр Петров", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+
+	manager, err := r.GetManagerByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetManagerByID: %v", err)
+	}
+	if manager.Username != username || manager.FullName != "Петр Петров" {
+		t.Errorf("GetManagerByID = %+v", manager)
+	}
+	if manager.HireDate != hireDate {
+		t.Errorf("HireDate = %s, ожидалось %s", manager.HireDate, hireDate)
+	}
+
+	if _, err := r.CreateManager(ctx, uniqueName("manager"), "secret-password", "Петр Петров", model.Date{}); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("CreateManager без даты приема: ожидалась ErrInvalidInput, получено %v", err)
+	}
+}
+
+func testUsernameUnique(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("dup")
+
+	if _, err := r.CreateClient(ctx, username, "secret-password", "Первый", "+79990000001"); err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if _, err := r.CreateManager(ctx, username, "secret-password", "Второй", hireDate); !errors.Is(err, database.ErrUsernameTaken) {
+		t.Errorf("создание второго пользователя с тем же именем: %v, ожидалась ErrUsernameTaken", err)
+	}
+}
+
+func testSoftDelete(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("deleted")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Удаляемый", "+79990000002")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+
+	if _, err := r.GetClientByID(ctx, id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("GetClientByID удаленного клиента: %v, ожидалась ErrNotFound", err)
+	}
+	if _, err := r.GetUserByID(ctx, id); err == nil {
+		t.Error("GetUserByID вернул удаленного пользователя")
+	}
+	if _, err := r.GetUserByUsername(ctx, username); err == nil {
+		t.Error("GetUserByUsername вернул удаленного пользователя")
+	}
+
+	users, err := r.GetAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	for _, u := range users {
+		if u.ID == id {
+			t.Error("GetAllUsers вернул удаленного пользователя")
+		}
+	}
+
+	if err := r.DeleteClient(ctx, id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("повторное удаление клиента: %v, ожидалась ErrNotFound", err)
+	}
+}
+
+func testDeleteWrongRole(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateClient(ctx, uniqueName("role"), "secret-password", "Клиент", "+79990000003")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if err := r.DeleteManager(ctx, id); err == nil {
+		t.Error("DeleteManager удалил клиента")
+	}
+	if _, err := r.GetClientByID(ctx, id); err != nil {
+		t.Errorf("клиент пропал после DeleteManager: %v", err)
+	}
+}
+
+func testLogAction(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateClient(ctx, uniqueName("log"), "secret-password", "Клиент", "+79990000004")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if err := r.LogAction(ctx, id, "Проверка журнала"); err != nil {
+		t.Errorf("LogAction: %v", err)
+	}
+	if err := r.LogAction(ctx, "00000000-0000-0000-0000-000000000000", "Несуществующий пользователь"); err == nil {
+		t.Error("LogAction записал действие несуществующего пользователя")
+	}
+	if err := r.LogAction(ctx, "", "Действие без пользователя"); err != nil {
+		t.Errorf("LogAction без пользователя: %v", err)
+	}
+}
+
+func testValidation(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	_, err := r.CreateClient(ctx, "1bad name", "secret-password", "И", "8 999 123-45-67")
+	if !errors.Is(err, database.ErrInvalidInput) {
+		t.Fatalf("CreateClient с некорректными данными: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	var verr model.ValidationErrors
+	if !errors.As(err, &verr) {
+		t.Fatalf("ошибка %v не содержит model.ValidationErrors", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verr {
+		fields[fe.Field] = true
+	}
+	for _, field := range []string{"username", "full_name", "phone_number"} {
+		if !fields[field] {
+			t.Errorf("нет ошибки поля %s в %v", field, verr)
+		}
+	}
+
+	future := model.DateOf(time.Now().AddDate(0, 0, 2))
+	if _, err := r.CreateManager(ctx, uniqueName("future"), "secret-password", "Менеджер", future); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("CreateManager с датой приема в будущем: ожидалась ErrInvalidInput, получено %v", err)
+	}
+
+	id, err := r.CreateClient(ctx, uniqueName("valid"), "secret-password", "Клиент", "+79990000015")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	phone := "+7 999"
+	if _, err := r.UpdateClient(ctx, id, model.ClientPatch{PhoneNumber: &phone}); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("UpdateClient с некорректным телефоном: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	username := "x"
+	if _, err := r.UpdateUser(ctx, id, model.UserPatch{Username: &username}); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("UpdateUser с некорректным именем: ожидалась ErrInvalidInput, получено %v", err)
+	}
+}
+
+func testPasswordPolicy(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("policy")
+
+	cases := []struct {
+		password string
+		code     string
+	}{
+		{"short", util.ViolationTooShort},
+		{strings.Repeat("пароль-", 12), util.ViolationTooLong},
+		{"onlylowercaseletters", util.ViolationCharClasses},
+		{"x-" + username, util.ViolationSimilarToUsername},
+	}
+	for _, c := range cases {
+		_, err := r.CreateClient(ctx, username, c.password, "Клиент", "+79990000017")
+		if !errors.Is(err, database.ErrInvalidInput) {
+			t.Errorf("CreateClient с паролем %q: ожидалась ErrInvalidInput, получено %v", c.password, err)
+			continue
+		}
+		var policyErr *util.PasswordPolicyError
+		if !errors.As(err, &policyErr) || !policyErr.Has(c.code) {
+			t.Errorf("CreateClient с паролем %q: ожидалось нарушение %s, получено %v", c.password, c.code, err)
+		}
+	}
+
+	if _, err := r.GetUserByUsername(ctx, username); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("пользователь с паролем, нарушающим политику, создан: %v", err)
+	}
+}
+
+// checkPassword проверяет, что пароль пользователя username равен password
+func checkPassword(t *testing.T, r database.Repos, username, password string) {
+	t.Helper()
+	u, err := r.GetUserByUsername(context.Background(), username)
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if err := util.CheckPassword(u.PasswordHash, password); err != nil {
+		t.Errorf("пароль пользователя %s не совпадает с ожидаемым: %v", username, err)
+	}
+}
+
+func testChangePassword(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("passwd")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000018")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if validAfter, err := r.TokensValidAfter(ctx, id); err != nil || !validAfter.IsZero() {
+		t.Errorf("TokensValidAfter нового пользователя = %v, %v, ожидалось нулевое время", validAfter, err)
+	}
+
+	err = r.ChangePassword(ctx, id, "wrong-password", "new-secret-password")
+	if !errors.Is(err, database.ErrInvalidInput) || !errors.Is(err, util.ErrMismatchedPassword) {
+		t.Errorf("ChangePassword с неверным текущим паролем: получено %v", err)
+	}
+	var policyErr *util.PasswordPolicyError
+	if err := r.ChangePassword(ctx, id, "secret-password", "short"); !errors.As(err, &policyErr) {
+		t.Errorf("ChangePassword со слабым паролем: ожидалась PasswordPolicyError, получено %v", err)
+	}
+	checkPassword(t, r, username, "secret-password")
+
+	before := time.Now().Add(-time.Minute)
+	if err := r.ChangePassword(ctx, id, "secret-password", "new-secret-password"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	checkPassword(t, r, username, "new-secret-password")
+
+	validAfter, err := r.TokensValidAfter(ctx, id)
+	if err != nil {
+		t.Fatalf("TokensValidAfter: %v", err)
+	}
+	if !validAfter.After(before) {
+		t.Errorf("TokensValidAfter = %v, ожидалось время смены пароля", validAfter)
+	}
+
+	if err := r.ChangePassword(ctx, "00000000-0000-0000-0000-000000000000", "a", "b"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("ChangePassword несуществующего пользователя: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func testResetPassword(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("reset")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000019")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	if err := r.ResetPassword(ctx, "unknown-token", "new-secret-password"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("ResetPassword с неизвестным токеном: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	token, err := r.CreatePasswordResetToken(ctx, id, 0)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+
+	// Слабый пароль не расходует токен
+	if err := r.ResetPassword(ctx, token, "short"); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("ResetPassword со слабым паролем: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	if err := r.ResetPassword(ctx, token, "reset-secret-password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	checkPassword(t, r, username, "reset-secret-password")
+
+	if err := r.ResetPassword(ctx, token, "other-secret-password"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("повторный ResetPassword: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	// Новый токен отзывает предыдущий
+	first, err := r.CreatePasswordResetToken(ctx, id, time.Hour)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	if _, err := r.CreatePasswordResetToken(ctx, id, time.Hour); err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	if err := r.ResetPassword(ctx, first, "other-secret-password"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("ResetPassword отозванным токеном: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	expired, err := r.CreatePasswordResetToken(ctx, id, time.Millisecond)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := r.ResetPassword(ctx, expired, "other-secret-password"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("ResetPassword истекшим токеном: ожидалась ErrInvalidToken, получено %v", err)
+	}
+	checkPassword(t, r, username, "reset-secret-password")
+
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if _, err := r.CreatePasswordResetToken(ctx, id, time.Hour); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("CreatePasswordResetToken удаленного пользователя: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func testUpdateUser(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	taken := uniqueName("taken")
+
+	if _, err := r.CreateClient(ctx, taken, "secret-password", "Занятый", "+79990000005"); err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	original := uniqueName("rename")
+	id, err := r.CreateClient(ctx, original, "secret-password", "Клиент", "+79990000006")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	if _, err := r.UpdateUser(ctx, id, model.UserPatch{}); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("UpdateUser с пустым патчем: %v, ожидалась ErrInvalidInput", err)
+	}
+	if _, err := r.UpdateUser(ctx, id, model.UserPatch{Username: &taken}); !errors.Is(err, database.ErrUsernameTaken) {
+		t.Errorf("UpdateUser с занятым именем: %v, ожидалась ErrUsernameTaken", err)
+	}
+
+	renamed := uniqueName("renamed")
+	rule := database.LockoutRule{MaxFailures: 1, BaseLockout: time.Hour, Window: time.Hour}
+	if _, err := r.RegisterLoginFailure(ctx, database.UserLockoutKey(original), rule); err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	user, err := r.UpdateUser(ctx, id, model.UserPatch{Username: &renamed})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if user.Username != renamed || user.Role != "client" {
+		t.Errorf("UpdateUser = %+v, ожидалось имя %s", user, renamed)
+	}
+	if _, err := r.GetUserByUsername(ctx, renamed); err != nil {
+		t.Errorf("GetUserByUsername после переименования: %v", err)
+	}
+	// Блокировка переходит к новому имени, а прежнее имя освобождается без нее
+	if until, err := r.LoginLockedUntil(ctx, database.UserLockoutKey(renamed)); err != nil || until.IsZero() {
+		t.Errorf("LoginLockedUntil нового имени = %v, %v, ожидалась блокировка", until, err)
+	}
+	if until, err := r.LoginLockedUntil(ctx, database.UserLockoutKey(original)); err != nil || !until.IsZero() {
+		t.Errorf("LoginLockedUntil прежнего имени = %v, %v, ожидалось нулевое время", until, err)
+	}
+}
+
+func testUpdatePasswordHash(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("rehash")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000016")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	// Учетная запись, созданная до перехода на Argon2id
+	legacy, err := util.NewBcryptHasher(bcrypt.MinCost).Hash("secret-password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if err := r.UpdatePasswordHash(ctx, id, legacy); err != nil {
+		t.Fatalf("UpdatePasswordHash: %v", err)
+	}
+	got, err := r.GetUserByUsername(ctx, username)
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if got.PasswordHash != legacy {
+		t.Fatalf("PasswordHash = %q, ожидался хеш bcrypt", got.PasswordHash)
+	}
+
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if err := r.UpdatePasswordHash(ctx, id, legacy); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("UpdatePasswordHash удаленного пользователя: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func testLoginLockout(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	rule := database.LockoutRule{MaxFailures: 2, BaseLockout: time.Hour, MaxLockout: 3 * time.Hour, Window: time.Hour}
+	key := database.UserLockoutKey(uniqueName("nobody"))
+	other := database.IPLockoutKey("192.0.2.10")
+
+	until, err := r.RegisterLoginFailure(ctx, key, rule)
+	if err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if !until.IsZero() {
+		t.Fatalf("после первой попытки ключ заблокирован до %v", until)
+	}
+	if until, err := r.LoginLockedUntil(ctx, key, other); err != nil || !until.IsZero() {
+		t.Fatalf("LoginLockedUntil = %v, %v, ожидалось нулевое время", until, err)
+	}
+
+	first, err := r.RegisterLoginFailure(ctx, key, rule)
+	if err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if d := time.Until(first); d < 50*time.Minute || d > 70*time.Minute {
+		t.Fatalf("первая блокировка на %v, ожидался час", d)
+	}
+	if until, err := r.LoginLockedUntil(ctx, other, key); err != nil || !until.Equal(first) {
+		t.Errorf("LoginLockedUntil = %v, %v, ожидалось %v", until, err, first)
+	}
+
+	// Каждая следующая блокировка вдвое длиннее, но не дольше MaxLockout
+	second, err := r.RegisterLoginFailure(ctx, key, rule)
+	if err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if d := time.Until(second); d < 110*time.Minute || d > 130*time.Minute {
+		t.Errorf("вторая блокировка на %v, ожидалось два часа", d)
+	}
+	third, err := r.RegisterLoginFailure(ctx, key, rule)
+	if err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if d := time.Until(third); d < 170*time.Minute || d > 190*time.Minute {
+		t.Errorf("третья блокировка на %v, ожидалось три часа", d)
+	}
+
+	if err := r.ResetLoginFailures(ctx, key); err != nil {
+		t.Fatalf("ResetLoginFailures: %v", err)
+	}
+	if until, err := r.LoginLockedUntil(ctx, key); err != nil || !until.IsZero() {
+		t.Errorf("после сброса LoginLockedUntil = %v, %v, ожидалось нулевое время", until, err)
+	}
+
+	if err := r.UnlockUser(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("UnlockUser несуществующего пользователя: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	// Устаревшие счетчики удаляются, действующая блокировка сохраняется
+	locked := database.UserLockoutKey(uniqueName("locked"))
+	stale := database.UserLockoutKey(uniqueName("stale"))
+	lockRule := database.LockoutRule{MaxFailures: 1, BaseLockout: time.Hour}
+	if _, err := r.RegisterLoginFailure(ctx, locked, lockRule); err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if _, err := r.RegisterLoginFailure(ctx, stale, rule); err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if _, err := r.PurgeExpiredLoginAttempts(ctx, time.Hour); err != nil {
+		t.Fatalf("PurgeExpiredLoginAttempts: %v", err)
+	}
+	if until, err := r.LoginLockedUntil(ctx, locked); err != nil || until.IsZero() {
+		t.Errorf("LoginLockedUntil = %v, %v, блокировка снята раньше срока", until, err)
+	}
+	if _, err := r.PurgeExpiredLoginAttempts(ctx, -time.Minute); err != nil {
+		t.Fatalf("PurgeExpiredLoginAttempts: %v", err)
+	}
+	if until, err := r.LoginLockedUntil(ctx, locked); err != nil || until.IsZero() {
+		t.Errorf("LoginLockedUntil = %v, %v, удален счетчик с действующей блокировкой", until, err)
+	}
+	// Счетчик удален, поэтому следующая попытка считается первой и не блокирует ключ
+	if until, err := r.RegisterLoginFailure(ctx, stale, rule); err != nil || !until.IsZero() {
+		t.Errorf("RegisterLoginFailure после очистки = %v, %v, ожидалось нулевое время", until, err)
+	}
+}
+
+func testMFARepository(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateManager(ctx, uniqueName("manager"), "secret-password", "Менеджер", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+	if _, err := r.GetMFA(ctx, id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("GetMFA до подключения: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	// Неподтвержденный секрет можно заменить
+	if err := r.SaveMFASecret(ctx, id, "FIRST"); err != nil {
+		t.Fatalf("SaveMFASecret: %v", err)
+	}
+	if err := r.SaveMFASecret(ctx, id, "SECOND"); err != nil {
+		t.Fatalf("повторный SaveMFASecret: %v", err)
+	}
+	got, err := r.GetMFA(ctx, id)
+	if err != nil {
+		t.Fatalf("GetMFA: %v", err)
+	}
+	if got.Secret != "SECOND" || got.Confirmed() {
+		t.Errorf("GetMFA = %+v, ожидался неподтвержденный секрет SECOND", got)
+	}
+	if err := r.UseMFAStep(ctx, id, 10); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("UseMFAStep до подтверждения: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	if err := r.ConfirmMFA(ctx, id, 10, []string{"code-a", "code-b"}); err != nil {
+		t.Fatalf("ConfirmMFA: %v", err)
+	}
+	if err := r.ConfirmMFA(ctx, id, 11, nil); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("повторный ConfirmMFA: ожидалась ErrNotFound, получено %v", err)
+	}
+	if err := r.SaveMFASecret(ctx, id, "THIRD"); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("SaveMFASecret после подтверждения: ожидалась ErrConflict, получено %v", err)
+	}
+
+	// Шаг принимается только один раз и только по возрастанию
+	if err := r.UseMFAStep(ctx, id, 10); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("шаг подтверждения: ожидалась ErrInvalidToken, получено %v", err)
+	}
+	if err := r.UseMFAStep(ctx, id, 12); err != nil {
+		t.Errorf("UseMFAStep: %v", err)
+	}
+	if err := r.UseMFAStep(ctx, id, 11); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("предыдущий шаг: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	if err := r.UseRecoveryCode(ctx, id, "code-a"); err != nil {
+		t.Errorf("UseRecoveryCode: %v", err)
+	}
+	if err := r.UseRecoveryCode(ctx, id, "code-a"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("повторный код восстановления: ожидалась ErrInvalidToken, получено %v", err)
+	}
+	if err := r.ReplaceRecoveryCodes(ctx, id, []string{"code-c"}); err != nil {
+		t.Fatalf("ReplaceRecoveryCodes: %v", err)
+	}
+	if err := r.UseRecoveryCode(ctx, id, "code-b"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("замененный код восстановления: ожидалась ErrInvalidToken, получено %v", err)
+	}
+	if err := r.UseRecoveryCode(ctx, id, "code-c"); err != nil {
+		t.Errorf("UseRecoveryCode нового кода: %v", err)
+	}
+
+	if err := r.DisableMFA(ctx, id); err != nil {
+		t.Fatalf("DisableMFA: %v", err)
+	}
+	if _, err := r.GetMFA(ctx, id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("GetMFA после отключения: ожидалась ErrNotFound, получено %v", err)
+	}
+	if err := r.ReplaceRecoveryCodes(ctx, id, []string{"code-d"}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("ReplaceRecoveryCodes после отключения: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func testRefreshTokens(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	id, err := r.CreateClient(ctx, uniqueName("client"), "secret-password", "Клиент", "+79990000023")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	first, err := r.CreateRefreshToken(ctx, id, uniqueName("jti"), "conformance", expiresAt)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	if first.UserID != id || first.FamilyID == "" || first.Device != "conformance" {
+		t.Errorf("CreateRefreshToken = %+v", first)
+	}
+	if _, err := r.CreateRefreshToken(ctx, "00000000-0000-0000-0000-000000000000", uniqueName("jti"), "", expiresAt); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("CreateRefreshToken несуществующего пользователя: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	jti, next := uniqueName("jti"), uniqueName("jti")
+	second, err := r.CreateRefreshToken(ctx, id, jti, "conformance", expiresAt)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	rotated, err := r.RotateRefreshToken(ctx, jti, next, expiresAt)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if rotated.FamilyID != second.FamilyID || rotated.ParentHash == nil || *rotated.ParentHash != database.HashTokenID(jti) {
+		t.Errorf("RotateRefreshToken = %+v, ожидался токен семейства %s", rotated, second.FamilyID)
+	}
+	if _, err := r.RotateRefreshToken(ctx, uniqueName("unknown"), uniqueName("jti"), expiresAt); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("неизвестный токен: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	// Повторная ротация отзывает все семейство, в том числе выданный при ротации токен
+	latest := uniqueName("jti")
+	if _, err := r.RotateRefreshToken(ctx, next, latest, expiresAt); err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	// Другие семейства отзыв не затрагивает
+	if _, err := r.RotateRefreshToken(ctx, jti, uniqueName("jti"), expiresAt); !errors.Is(err, database.ErrTokenReused) {
+		t.Fatalf("повторная ротация: ожидалась ErrTokenReused, получено %v", err)
+	}
+	if _, err := r.RotateRefreshToken(ctx, latest, uniqueName("jti"), expiresAt); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("токен отозванного семейства: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	// Внутри WithTx отзыв выполняется в транзакции вызывающего кода и сохраняется при ее фиксации
+	reused, rotatedJTI := uniqueName("jti"), uniqueName("jti")
+	if _, err := r.CreateRefreshToken(ctx, id, reused, "conformance", expiresAt); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	if _, err := r.RotateRefreshToken(ctx, reused, rotatedJTI, expiresAt); err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	err = r.WithTx(ctx, func(tx database.Repos) error {
+		if _, err := tx.RotateRefreshToken(ctx, reused, uniqueName("jti"), expiresAt); !errors.Is(err, database.ErrTokenReused) {
+			t.Errorf("повторная ротация в транзакции: ожидалась ErrTokenReused, получено %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := r.RotateRefreshToken(ctx, rotatedJTI, uniqueName("jti"), expiresAt); !errors.Is(err, database.ErrInvalidToken) || errors.Is(err, database.ErrTokenReused) {
+		t.Errorf("после фиксации транзакции семейство не отозвано: %v", err)
+	}
+
+	if err := r.RevokeRefreshFamily(ctx, first.FamilyID); err != nil {
+		t.Fatalf("RevokeRefreshFamily: %v", err)
+	}
+	if err := r.RevokeRefreshFamily(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("RevokeRefreshFamily несуществующего сеанса: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func testTokenRevocation(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateClient(ctx, uniqueName("client"), "secret-password", "Клиент", "+79990000024")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	jti := uniqueName("jti")
+	if revoked, err := r.TokenRevoked(ctx, jti); err != nil || revoked {
+		t.Fatalf("TokenRevoked до отзыва = %v, %v", revoked, err)
+	}
+	if err := r.RevokeToken(ctx, id, jti, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if err := r.RevokeToken(ctx, id, jti, time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("повторный RevokeToken: %v", err)
+	}
+	if revoked, err := r.TokenRevoked(ctx, jti); err != nil || !revoked {
+		t.Errorf("TokenRevoked = %v, %v, ожидался отзыв", revoked, err)
+	}
+	if n, err := r.PurgeExpiredRevocations(ctx); err != nil || n != 0 {
+		t.Errorf("PurgeExpiredRevocations = %d, %v, действующие записи не должны удаляться", n, err)
+	}
+
+	before, err := r.TokensValidAfter(ctx, id)
+	if err != nil {
+		t.Fatalf("TokensValidAfter: %v", err)
+	}
+	jti = uniqueName("jti")
+	if _, err := r.CreateRefreshToken(ctx, id, jti, "conformance", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	if err := r.RevokeAllForUser(ctx, id); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	after, err := r.TokensValidAfter(ctx, id)
+	if err != nil {
+		t.Fatalf("TokensValidAfter: %v", err)
+	}
+	if !after.After(before) {
+		t.Errorf("TokensValidAfter = %v, ожидалось позже %v", after, before)
+	}
+	// Сеансы пользователя завершаются
+	if _, err := r.RotateRefreshToken(ctx, jti, uniqueName("jti"), time.Now().Add(time.Hour)); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("рефреш токен после RevokeAllForUser: ожидалась ErrInvalidToken, получено %v", err)
+	}
+	if err := r.RevokeAllForUser(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("RevokeAllForUser несуществующего пользователя: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func testUpdateAdminPermissions(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateAdmin(ctx, uniqueName("admin"), "secret-password", map[string]interface{}{"users": true})
+	if err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+
+	admin, err := r.UpdateAdminPermissions(ctx, id, map[string]interface{}{"logs": true})
+	if err != nil {
+		t.Fatalf("UpdateAdminPermissions: %v", err)
+	}
+	if admin.Permissions["logs"] != true || admin.Permissions["users"] != nil {
+		t.Errorf("Permissions = %v, ожидалось только logs=true", admin.Permissions)
+	}
+
+	// nil сохранился бы как JSON null, а не как пустой набор прав
+	if _, err := r.UpdateAdminPermissions(ctx, id, nil); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("UpdateAdminPermissions(nil): ожидалась ErrInvalidInput, получено %v", err)
+	}
+	admin, err = r.UpdateAdminPermissions(ctx, id, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("UpdateAdminPermissions с пустыми правами: %v", err)
+	}
+	if admin.Permissions == nil || len(admin.Permissions) != 0 {
+		t.Errorf("Permissions = %#v, ожидался пустой набор прав", admin.Permissions)
+	}
+	if _, err := r.CreateAdmin(ctx, uniqueName("admin"), "secret-password", nil); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("CreateAdmin с nil правами: ожидалась ErrInvalidInput, получено %v", err)
+	}
+
+	clientID, err := r.CreateClient(ctx, uniqueName("client"), "secret-password", "Клиент", "+79990000007")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if _, err := r.UpdateAdminPermissions(ctx, clientID, map[string]interface{}{}); err == nil {
+		t.Error("UpdateAdminPermissions обновил клиента")
+	}
+}
+
+func testUpdateClient(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateClient(ctx, uniqueName("client"), "secret-password", "Старое Имя", "+79990000008")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	phone := "+79990000009"
+	client, err := r.UpdateClient(ctx, id, model.ClientPatch{PhoneNumber: &phone})
+	if err != nil {
+		t.Fatalf("UpdateClient: %v", err)
+	}
+	if client.PhoneNumber != phone || client.FullName != "Старое Имя" {
+		t.Errorf("UpdateClient = %+v, ожидалось изменение только телефона", client)
+	}
+
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if _, err := r.UpdateClient(ctx, id, model.ClientPatch{PhoneNumber: &phone}); err == nil {
+		t.Error("UpdateClient обновил удаленного клиента")
+	}
+}
+
+func testUpdateManager(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateManager(ctx, uniqueName("manager"), "secret-password", "Менеджер", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+
+	newHireDate := model.Date{Year: 2023, Month: time.January, Day: 15}
+	manager, err := r.UpdateManager(ctx, id, model.ManagerPatch{HireDate: &newHireDate})
+	if err != nil {
+		t.Fatalf("UpdateManager: %v", err)
+	}
+	if manager.HireDate != newHireDate || manager.FullName != "Менеджер" {
+		t.Errorf("UpdateManager = %+v, ожидалось изменение только даты приема", manager)
+	}
+
+	var invalid model.Date
+	if _, err := r.UpdateManager(ctx, id, model.ManagerPatch{HireDate: &invalid}); err == nil {
+		t.Error("UpdateManager принял пустую дату приема")
+	}
+}
+
+func testRestoreUser(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateManager(ctx, uniqueName("restore"), "secret-password", "Менеджер", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+	if _, err := r.RestoreUser(ctx, id); err == nil {
+		t.Error("RestoreUser восстановил неудаленного пользователя")
+	}
+	if err := r.DeleteManager(ctx, id); err != nil {
+		t.Fatalf("DeleteManager: %v", err)
+	}
+
+	if !listedAsDeleted(t, r, id) {
+		t.Error("ListDeletedUsers не вернул удаленного менеджера")
+	}
+
+	user, err := r.RestoreUser(ctx, id)
+	if err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	if user.ID != id || user.Role != "manager" {
+		t.Errorf("RestoreUser = %+v", user)
+	}
+	if _, err := r.GetManagerByID(ctx, id); err != nil {
+		t.Errorf("GetManagerByID после восстановления: %v", err)
+	}
+	if listedAsDeleted(t, r, id) {
+		t.Error("ListDeletedUsers вернул восстановленного менеджера")
+	}
+}
+
+func testPurgeUser(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	username := uniqueName("purge")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000010")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if err := r.PurgeUser(ctx, id, database.PurgeOptions{}); err == nil {
+		t.Error("PurgeUser удалил активного пользователя")
+	}
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	key := database.UserLockoutKey(username)
+	rule := database.LockoutRule{MaxFailures: 1, BaseLockout: time.Hour, Window: time.Hour}
+	if _, err := r.RegisterLoginFailure(ctx, key, rule); err != nil {
+		t.Fatalf("RegisterLoginFailure: %v", err)
+	}
+	if err := r.PurgeUser(ctx, id, database.PurgeOptions{AnonymizeLogs: true}); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	// Блокировка по имени пользователя удаляется вместе с ним и не переходит к новому владельцу имени
+	if until, err := r.LoginLockedUntil(ctx, key); err != nil || !until.IsZero() {
+		t.Errorf("LoginLockedUntil после PurgeUser = %v, %v, ожидалось нулевое время", until, err)
+	}
+
+	if listedAsDeleted(t, r, id) {
+		t.Error("ListDeletedUsers вернул физически удаленного пользователя")
+	}
+	if _, err := r.RestoreUser(ctx, id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("RestoreUser физически удаленного пользователя: %v, ожидалась ErrNotFound", err)
+	}
+	// Имя пользователя освобождается после физического удаления
+	if _, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000010"); err != nil {
+		t.Errorf("CreateClient с именем удаленного пользователя: %v", err)
+	}
+}
+
+func testPurgeDeletedOlderThan(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateClient(ctx, uniqueName("retention"), "secret-password", "Клиент", "+79990000011")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+
+	if _, err := r.PurgeDeletedOlderThan(ctx, 24*time.Hour); err != nil {
+		t.Fatalf("PurgeDeletedOlderThan: %v", err)
+	}
+	if !listedAsDeleted(t, r, id) {
+		t.Error("PurgeDeletedOlderThan удалил пользователя раньше срока хранения")
+	}
+
+	n, err := r.PurgeDeletedOlderThan(ctx, -time.Minute)
+	if err != nil {
+		t.Fatalf("PurgeDeletedOlderThan: %v", err)
+	}
+	if n < 1 || listedAsDeleted(t, r, id) {
+		t.Errorf("PurgeDeletedOlderThan удалил %d пользователей, ожидалось удаление %s", n, id)
+	}
+}
+
+// listedAsDeleted проверяет, есть ли пользователь среди логически удаленных
+func listedAsDeleted(t *testing.T, r database.Repos, id string) bool {
+	t.Helper()
+
+	users, err := r.ListDeletedUsers(context.Background())
+	if err != nil {
+		t.Fatalf("ListDeletedUsers: %v", err)
+	}
+	for _, u := range users {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func testListPagination(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	base := uniqueName("list")
+	names := []string{base + "_1", base + "_2", base + "_3"}
+
+	for _, name := range names {
+		if _, err := r.CreateClient(ctx, name, "secret-password", "Клиент", "+79990000012"); err != nil {
+			t.Fatalf("CreateClient: %v", err)
+		}
+	}
+
+	for _, desc := range []bool{false, true} {
+		var got []string
+		seen := map[string]bool{}
+		opts := database.ListOptions{Limit: 2, SortBy: database.SortByUsername, Descending: desc}
+		for {
+			page, err := r.ListClients(ctx, opts)
+			if err != nil {
+				t.Fatalf("ListClients: %v", err)
+			}
+			if len(page.Items) > 2 {
+				t.Fatalf("ListClients вернул %d записей при Limit 2", len(page.Items))
+			}
+			for _, c := range page.Items {
+				if seen[c.ID] {
+					t.Fatalf("клиент %s встретился на двух страницах", c.Username)
+				}
+				seen[c.ID] = true
+				if len(c.Username) > len(base) && c.Username[:len(base)] == base {
+					got = append(got, c.Username)
+				}
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+
+		want := names
+		if desc {
+			want = []string{names[2], names[1], names[0]}
+		}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+			t.Errorf("порядок при Descending=%v: %v, ожидалось %v", desc, got, want)
+		}
+	}
+
+	if _, err := r.ListClients(ctx, database.ListOptions{Cursor: "not-a-cursor"}); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("ListClients с некорректным курсором: %v, ожидалась ErrInvalidInput", err)
+	}
+}
+
+func testListFilters(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+
+	id, err := r.CreateManager(ctx, uniqueName("filter"), "secret-password", "Менеджер", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+	if err := r.DeleteManager(ctx, id); err != nil {
+		t.Fatalf("DeleteManager: %v", err)
+	}
+
+	find := func(opts database.ListOptions) (model.Manager, bool) {
+		t.Helper()
+		opts.Limit = database.MaxListLimit
+		for {
+			page, err := r.ListManagers(ctx, opts)
+			if err != nil {
+				t.Fatalf("ListManagers: %v", err)
+			}
+			for _, m := range page.Items {
+				if m.ID == id {
+					return m, true
+				}
+			}
+			if page.NextCursor == "" {
+				return model.Manager{}, false
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	if _, ok := find(database.ListOptions{}); ok {
+		t.Error("ListManagers вернул удаленного менеджера без IncludeDeleted")
+	}
+	m, ok := find(database.ListOptions{IncludeDeleted: true, SortBy: database.SortByHireDate})
+	if !ok || !m.IsDeleted {
+		t.Errorf("ListManagers с IncludeDeleted: найден=%v, IsDeleted=%v", ok, m.IsDeleted)
+	}
+	if _, ok := find(database.ListOptions{IncludeDeleted: true, CreatedTo: time.Now().Add(-24 * time.Hour)}); ok {
+		t.Error("ListManagers не учел CreatedTo")
+	}
+
+	page, err := r.ListUsers(ctx, database.ListOptions{Role: "admin", Limit: database.MaxListLimit})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	for _, u := range page.Items {
+		if u.Role != "admin" {
+			t.Errorf("ListUsers с Role=admin вернул пользователя с ролью %s", u.Role)
+		}
+		if u.PasswordHash != "" {
+			t.Error("ListUsers вернул хеш пароля")
+		}
+	}
+
+	if _, err := r.ListUsers(ctx, database.ListOptions{SortBy: database.SortByHireDate}); err == nil {
+		t.Error("ListUsers принял сортировку по hire_date")
+	}
+}
+
+func testSearchClients(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	suffix := uniqueName("")[1:9]
+	fullName := "Клиентов " + suffix
+	digits := fmt.Sprintf("%04d", time.Now().UnixNano()%10000)
+
+	id, err := r.CreateClient(ctx, uniqueName("search"), "secret-password", fullName, "+7999555"+digits)
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	found := func(opts database.SearchOptions) (float64, bool) {
+		t.Helper()
+		opts.Limit = database.MaxListLimit
+		result, err := r.SearchClients(ctx, opts)
+		if err != nil {
+			t.Fatalf("SearchClients(%q): %v", opts.Query, err)
+		}
+		for i, hit := range result.Hits {
+			if i > 0 && hit.Score > result.Hits[i-1].Score {
+				t.Errorf("результаты поиска %q не упорядочены по рангу", opts.Query)
+			}
+			if hit.Item.ID == id {
+				return hit.Score, true
+			}
+		}
+		return 0, false
+	}
+
+	if _, ok := found(database.SearchOptions{Query: suffix}); !ok {
+		t.Errorf("клиент не найден по части ФИО %q", suffix)
+	}
+	if _, ok := found(database.SearchOptions{Query: strings.ToUpper(fullName)}); !ok {
+		t.Error("поиск по ФИО зависит от регистра")
+	}
+
+	score, ok := found(database.SearchOptions{Query: "55-" + digits})
+	if !ok || score != database.PhoneScore {
+		t.Errorf("клиент не найден по последним цифрам телефона %q: найден=%v, ранг=%v", digits, ok, score)
+	}
+
+	if _, err := r.SearchClients(ctx, database.SearchOptions{Query: "  "}); err == nil {
+		t.Error("SearchClients принял пустой запрос")
+	}
+
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if _, ok := found(database.SearchOptions{Query: suffix}); ok {
+		t.Error("SearchClients нашел удаленного клиента")
+	}
+	if _, ok := found(database.SearchOptions{Query: suffix, IncludeDeleted: true}); !ok {
+		t.Error("SearchClients не нашел удаленного клиента с IncludeDeleted")
+	}
+}
+
+func testSearchManagers(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	base := uniqueName("srch")
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.CreateManager(ctx, fmt.Sprintf("%s_%d", base, i), "secret-password", "Менеджер", hireDate); err != nil {
+			t.Fatalf("CreateManager: %v", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	opts := database.SearchOptions{Query: base, Limit: 2}
+	for {
+		result, err := r.SearchManagers(ctx, opts)
+		if err != nil {
+			t.Fatalf("SearchManagers: %v", err)
+		}
+		for _, hit := range result.Hits {
+			if seen[hit.Item.ID] {
+				t.Fatalf("менеджер %s встретился на двух страницах", hit.Item.Username)
+			}
+			seen[hit.Item.ID] = true
+		}
+		if result.NextOffset == 0 {
+			break
+		}
+		opts.Offset = result.NextOffset
+	}
+	if len(seen) < 3 {
+		t.Errorf("SearchManagers нашел %d менеджеров, ожидалось не меньше 3", len(seen))
+	}
+
+	users, err := r.SearchUsers(ctx, database.SearchOptions{Query: base, Role: "client"})
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if len(users.Hits) != 0 {
+		t.Errorf("SearchUsers с Role=client вернул %d менеджеров", len(users.Hits))
+	}
+}
+
+func testWithTx(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	rollback := errors.New("откат")
+
+	var rolledBack string
+	err := r.WithTx(ctx, func(tx database.Repos) error {
+		id, err := tx.CreateClient(ctx, uniqueName("tx"), "secret-password", "Клиент", "+79990000013")
+		if err != nil {
+			return err
+		}
+		rolledBack = id
+		if _, err := tx.GetClientByID(ctx, id); err != nil {
+			t.Errorf("клиент не виден внутри транзакции: %v", err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("WithTx вернул %v, ожидалась ошибка fn", err)
+	}
+	if _, err := r.GetUserByID(ctx, rolledBack); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("клиент из откаченной транзакции: %v, ожидалась ErrNotFound", err)
+	}
+
+	var clientID, managerID string
+	err = r.WithTx(ctx, func(tx database.Repos) error {
+		var err error
+		if clientID, err = tx.CreateClient(ctx, uniqueName("tx"), "secret-password", "Клиент", "+79990000014"); err != nil {
+			return err
+		}
+		managerID, err = tx.CreateManager(ctx, uniqueName("tx"), "secret-password", "Менеджер", hireDate)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := r.GetClientByID(ctx, clientID); err != nil {
+		t.Errorf("клиент после фиксации транзакции: %v", err)
+	}
+	if _, err := r.GetManagerByID(ctx, managerID); err != nil {
+		t.Errorf("менеджер после фиксации транзакции: %v", err)
+	}
+}
+
+// testInvalidID проверяет, что идентификатор, который нельзя привести к uuid, отклоняется как некорректный ввод
+func testInvalidID(t *testing.T, r database.Repos) {
+	ctx := context.Background()
+	const id = "not-a-uuid"
+
+	if _, err := r.GetUserByID(ctx, id); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("GetUserByID: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	if _, err := r.GetClientByID(ctx, id); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("GetClientByID: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	if err := r.DeleteManager(ctx, id); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("DeleteManager: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	if _, err := r.TokensValidAfter(ctx, id); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("TokensValidAfter: ожидалась ErrInvalidInput, получено %v", err)
+	}
+	if _, err := r.GetMFA(ctx, id); !errors.Is(err, database.ErrInvalidInput) {
+		t.Errorf("GetMFA: ожидалась ErrInvalidInput, получено %v", err)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/Maden-in-haven/crmlib/pkg/util"
-	"time"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
 )
 
-func (db *db) CreateAdmin(ctx context.Context, username, password string, permissions map[string]interface{}) (string, error) {
+func (db *Store) CreateAdmin(ctx context.Context, username, password string, permissions map[string]interface{}) (string, error) {
+	input := model.CreateAdminInput{Username: username, Password: password, Permissions: permissions}
+	if err := input.Validate(); err != nil {
+		return "", Invalid(err)
+	}
+
 	query := `SELECT create_admin($1, $2, $3)`
 
 	var adminID string
@@ -16,127 +20,215 @@ func (db *db) CreateAdmin(ctx context.Context, username, password string, permis
 	// Преобразование карты permissions в JSONB формат
 	permissionsJSON, err := json.Marshal(permissions)
 	if err != nil {
-		return "", fmt.Errorf("ошибка преобразования permissions в JSON: %v", err)
+		return "", &Error{Kind: ErrInvalidInput, Msg: "ошибка преобразования permissions в JSON", Err: err}
+	}
+	passwordHash, err := PreparePassword(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return "", err
 	}
-	passwordHash, _ := util.HashPassword(password)
+	defer tx.rollback(ctx)
+
 	// Выполнение запроса для вызова хранимой функции
-	err = db.Pool.QueryRow(ctx, query, username, passwordHash, permissionsJSON).Scan(&adminID)
+	err = tx.conn().QueryRow(ctx, query, username, passwordHash, permissionsJSON).Scan(&adminID)
 	if err != nil {
-		return "", fmt.Errorf("ошибка вызова хранимой функции create_admin: %v", err)
+		return "", wrapError("ошибка вызова хранимой функции create_admin", err)
 	}
 
 	// Логирование действия
-	err = db.LogAction(ctx, adminID, fmt.Sprintf("Администратор %s был создан", username))
+	err = tx.LogAction(ctx, adminID, fmt.Sprintf("Администратор %s был создан", username))
 	if err != nil {
-		return "", fmt.Errorf("ошибка записи лога: %v", err)
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return "", err
 	}
 
 	// Возвращаем ID нового администратора
 	return adminID, nil
 }
 
-func (db *db) CreateClient(ctx context.Context, username, password, fullName, phoneNumber string) (string, error) {
+func (db *Store) CreateClient(ctx context.Context, username, password, fullName, phoneNumber string) (string, error) {
+	input := model.CreateClientInput{Username: username, Password: password, FullName: fullName, PhoneNumber: phoneNumber}
+	if err := input.Validate(); err != nil {
+		return "", Invalid(err)
+	}
+
 	// SQL-запрос для вызова хранимой функции create_client
 	query := `SELECT create_client($1, $2, $3, $4)`
 
 	var clientID string
-	passwordHash, _ := util.HashPassword(password)
+	passwordHash, err := PreparePassword(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.rollback(ctx)
+
 	// Выполнение запроса для вызова хранимой функции
-	err := db.Pool.QueryRow(ctx, query, username, passwordHash, fullName, phoneNumber).Scan(&clientID)
+	err = tx.conn().QueryRow(ctx, query, username, passwordHash, fullName, phoneNumber).Scan(&clientID)
 	if err != nil {
-		return "", fmt.Errorf("ошибка вызова хранимой функции create_client: %v", err)
+		return "", wrapError("ошибка вызова хранимой функции create_client", err)
 	}
 
 	// Логирование действия
-	err = db.LogAction(ctx, clientID, fmt.Sprintf("Клиент %s был создан", username))
+	err = tx.LogAction(ctx, clientID, fmt.Sprintf("Клиент %s был создан", username))
 	if err != nil {
-		return "", fmt.Errorf("ошибка записи лога: %v", err)
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return "", err
 	}
 
 	// Возвращаем ID нового клиента
 	return clientID, nil
 }
 
-func (db *db) CreateManager(ctx context.Context, username, password, fullName, hireDateStr string) (string, error) {
-	hireDate, err := time.Parse(time.RFC3339, hireDateStr)
-	if err != nil {
-		return "", fmt.Errorf("некорректный формат даты: %v", err)
+// CreateManager создает менеджера. Строку даты из прежнего API можно преобразовать через model.ParseDate
+func (db *Store) CreateManager(ctx context.Context, username, password, fullName string, hireDate model.Date) (string, error) {
+	input := model.CreateManagerInput{Username: username, Password: password, FullName: fullName, HireDate: hireDate}
+	if err := input.Validate(); err != nil {
+		return "", Invalid(err)
 	}
 
 	// SQL-запрос для вызова хранимой функции create_manager
 	query := `SELECT create_manager($1, $2, $3, $4)`
 
 	var managerID string
-	passwordHash, _ := util.HashPassword(password)
+	passwordHash, err := PreparePassword(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.rollback(ctx)
 
 	// Выполнение запроса для вызова хранимой функции
-	err = db.Pool.QueryRow(ctx, query, username, passwordHash, fullName, hireDate).Scan(&managerID)
+	err = tx.conn().QueryRow(ctx, query, username, passwordHash, fullName, hireDate).Scan(&managerID)
 	if err != nil {
-		return "", fmt.Errorf("ошибка вызова хранимой функции create_manager: %v", err)
+		return "", wrapError("ошибка вызова хранимой функции create_manager", err)
 	}
 
 	// Логирование действия
-	err = db.LogAction(ctx, managerID, fmt.Sprintf("Менеджер %s был создан", username))
+	err = tx.LogAction(ctx, managerID, fmt.Sprintf("Менеджер %s был создан", username))
 	if err != nil {
-		return "", fmt.Errorf("ошибка записи лога: %v", err)
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return "", err
 	}
 
 	// Возвращаем ID нового менеджера
 	return managerID, nil
 }
 
-func (db *db) DeleteAdmin(ctx context.Context, adminID string) error {
+func (db *Store) DeleteAdmin(ctx context.Context, adminID string) error {
 	// SQL-запрос для вызова хранимой функции delete_admin
 	query := `SELECT delete_admin($1)`
 
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
 	// Выполнение запроса для вызова хранимой функции
-	_, err := db.Pool.Exec(ctx, query, adminID)
+	_, err = tx.conn().Exec(ctx, query, adminID)
 	if err != nil {
-		return fmt.Errorf("ошибка вызова хранимой функции delete_admin: %v", err)
+		return wrapError("ошибка вызова хранимой функции delete_admin", err)
 	}
 
 	// Логирование действия
-	err = db.LogAction(ctx, adminID, "Администратор был логически удален")
+	err = tx.LogAction(ctx, adminID, "Администратор был логически удален")
 	if err != nil {
-		return fmt.Errorf("ошибка записи лога: %v", err)
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (db *db) DeleteClient(ctx context.Context, clientID string) error {
+func (db *Store) DeleteClient(ctx context.Context, clientID string) error {
 	// SQL-запрос для вызова хранимой функции delete_client
 	query := `SELECT delete_client($1)`
 
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
 	// Выполнение запроса для вызова хранимой функции
-	_, err := db.Pool.Exec(ctx, query, clientID)
+	_, err = tx.conn().Exec(ctx, query, clientID)
 	if err != nil {
-		return fmt.Errorf("ошибка вызова хранимой функции delete_client: %v", err)
+		return wrapError("ошибка вызова хранимой функции delete_client", err)
 	}
 
 	// Логирование действия
-	err = db.LogAction(ctx, clientID, "Клиент был логически удален")
+	err = tx.LogAction(ctx, clientID, "Клиент был логически удален")
 	if err != nil {
-		return fmt.Errorf("ошибка записи лога: %v", err)
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (db *db) DeleteManager(ctx context.Context, managerID string) error {
+func (db *Store) DeleteManager(ctx context.Context, managerID string) error {
 	// SQL-запрос для вызова хранимой функции delete_manager
 	query := `SELECT delete_manager($1)`
 
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
 	// Выполнение запроса для вызова хранимой функции
-	_, err := db.Pool.Exec(ctx, query, managerID)
+	_, err = tx.conn().Exec(ctx, query, managerID)
 	if err != nil {
-		return fmt.Errorf("ошибка вызова хранимой функции delete_manager: %v", err)
+		return wrapError("ошибка вызова хранимой функции delete_manager", err)
+	}
+
+	// Токены удаленного менеджера становятся недействительными сразу, а не по истечении срока
+	if err := tx.revokeAllTokens(ctx, managerID); err != nil {
+		return err
 	}
 
 	// Логирование действия
-	err = db.LogAction(ctx, managerID, "Менеджер был логически удален")
+	err = tx.LogAction(ctx, managerID, "Менеджер был логически удален")
 	if err != nil {
-		return fmt.Errorf("ошибка записи лога: %v", err)
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return err
 	}
 
 	return nil
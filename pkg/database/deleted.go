@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// PurgeOptions задает параметры физического удаления пользователя
+type PurgeOptions struct {
+	// AnonymizeLogs сохраняет записи user_logs без привязки к пользователю, с вырезанным именем пользователя
+	// и без сведений о клиенте (ClientInfoPattern). Если false, записи удаляются вместе с пользователем
+	AnonymizeLogs bool
+}
+
+// ClientInfoPattern — регулярное выражение для сведений о клиенте (IP-адрес и User-Agent),
+// которые user.Authenticator дописывает в конец записи журнала. Синтаксис совместим с Go и PostgreSQL
+const ClientInfoPattern = `(?s) \(IP: .*, User-Agent: .*\)$`
+
+// UsernamePattern возвращает регулярное выражение, находящее username в записи журнала отдельным словом:
+// короткое имя вроде "adm" не совпадает с частью другого слова. Группы 1 и 2 захватывают соседние символы,
+// поэтому замена должна их сохранять, а из-за захвата соседних символов для вхождений, разделенных одним
+// символом, замену нужно применить дважды. Синтаксис совместим с Go и PostgreSQL
+func UsernamePattern(username string) string {
+	return `(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(username) + `($|[^A-Za-z0-9_])`
+}
+
+// RestoreUser восстанавливает логически удаленного пользователя.
+// Время удаления хранится в updated_at, поэтому после восстановления оно перезаписывается
+func (db *Store) RestoreUser(ctx context.Context, userID string) (model.User, error) {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.User{}, err
+	}
+	defer tx.rollback(ctx)
+
+	query := `UPDATE users
+			  SET is_deleted = false, updated_at = now()
+			  WHERE id = $1 AND is_deleted = true
+			  RETURNING id, username, role, password_hash, created_at, updated_at`
+
+	var user model.User
+
+	err = tx.conn().QueryRow(ctx, query, userID).Scan(&user.ID, &user.Username, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, NotFound("удаленный пользователь с ID %s не найден", userID)
+		}
+		return user, wrapError("ошибка восстановления пользователя", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Пользователь был восстановлен")
+	if err != nil {
+		return user, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// ListDeletedUsers возвращает логически удаленных пользователей, начиная с удаленных последними.
+// UpdatedAt содержит время удаления
+func (db *Store) ListDeletedUsers(ctx context.Context) ([]model.User, error) {
+	query := `SELECT id, username, role, is_deleted, created_at, updated_at
+			  FROM users
+			  WHERE is_deleted = true
+			  ORDER BY updated_at DESC, id`
+
+	rows, err := db.conn().Query(ctx, query)
+	if err != nil {
+		return nil, wrapError("ошибка получения удаленных пользователей", err)
+	}
+
+	// Колонки сопоставляются с полями по тегам db, password_hash не выбирается
+	users, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[model.User])
+	if err != nil {
+		return nil, wrapError("ошибка получения удаленных пользователей", err)
+	}
+	return users, nil
+}
+
+// PurgeUser физически удаляет логически удаленного пользователя вместе с записями
+// в admins, clients, managers и его журналом. Активного пользователя нужно сначала удалить через Delete*
+func (db *Store) PurgeUser(ctx context.Context, userID string, opts PurgeOptions) error {
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	purged, err := purgeDeletedUser(ctx, tx, userID, opts)
+	if err != nil {
+		return err
+	}
+	if !purged {
+		return NotFound("удаленный пользователь с ID %s не найден", userID)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PurgeDeletedOlderThan физически удаляет пользователей, удаленных логически раньше, чем age назад.
+// Журнал таких пользователей анонимизируется. Возвращает количество удаленных пользователей
+func (db *Store) PurgeDeletedOlderThan(ctx context.Context, age time.Duration) (int, error) {
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback(ctx)
+
+	// Срок отсчитывается по часам базы данных, как и время удаления в updated_at
+	query := `SELECT id FROM users
+			  WHERE is_deleted = true AND updated_at < now() - $1::interval
+			  FOR UPDATE`
+
+	rows, err := tx.conn().Query(ctx, query, age)
+	if err != nil {
+		return 0, wrapError("ошибка получения удаленных пользователей", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, wrapError("ошибка получения удаленных пользователей", err)
+	}
+
+	for _, id := range ids {
+		if _, err := purgeDeletedUser(ctx, tx, id, PurgeOptions{AnonymizeLogs: true}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// purgeDeletedUser удаляет пользователя внутри транзакции хранилища tx.
+// Возвращает false, если логически удаленного пользователя с таким ID нет
+func purgeDeletedUser(ctx context.Context, tx *Store, userID string, opts PurgeOptions) (bool, error) {
+	var username string
+	err := tx.conn().QueryRow(ctx, `SELECT username FROM users WHERE id = $1 AND is_deleted = true FOR UPDATE`, userID).Scan(&username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, wrapError("ошибка получения пользователя", err)
+	}
+
+	if opts.AnonymizeLogs {
+		query := `UPDATE user_logs SET user_id = NULL,
+				  action = regexp_replace(regexp_replace(regexp_replace(action, $2, '\1***\2', 'g'), $2, '\1***\2', 'g'), $3, '')
+				  WHERE user_id = $1`
+		_, err = tx.conn().Exec(ctx, query, userID, UsernamePattern(username), ClientInfoPattern)
+	} else {
+		_, err = tx.conn().Exec(ctx, `DELETE FROM user_logs WHERE user_id = $1`, userID)
+	}
+	if err != nil {
+		return false, wrapError(fmt.Sprintf("ошибка очистки журнала пользователя с ID %s", userID), err)
+	}
+
+	// Счетчик неудачных попыток входа хранится по имени пользователя, а не по ID
+	if _, err := tx.conn().Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, UserLockoutKey(username)); err != nil {
+		return false, wrapError(fmt.Sprintf("ошибка удаления попыток входа пользователя с ID %s", userID), err)
+	}
+
+	// Удаляем записи ролей до записи пользователя из-за внешних ключей
+	for _, query := range []string{
+		`DELETE FROM admins WHERE id = $1`,
+		`DELETE FROM clients WHERE id = $1`,
+		`DELETE FROM managers WHERE id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	} {
+		if _, err := tx.conn().Exec(ctx, query, userID); err != nil {
+			return false, wrapError(fmt.Sprintf("ошибка удаления пользователя с ID %s", userID), err)
+		}
+	}
+
+	return true, nil
+}
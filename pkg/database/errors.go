@@ -0,0 +1,111 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Категории ошибок хранилища. Проверяются через errors.Is
+var (
+	ErrNotFound      = errors.New("запись не найдена")
+	ErrUsernameTaken = errors.New("имя пользователя уже занято")
+	ErrInvalidInput  = errors.New("некорректные входные данные")
+	ErrConflict      = errors.New("конфликт данных")
+	ErrInvalidToken  = errors.New("токен недействителен или истек")
+	ErrTokenReused   = errors.New("рефреш токен использован повторно")
+)
+
+// Коды ошибок PostgreSQL, которые отображаются на категории
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgNotNullViolation     = "23502"
+	pgCheckViolation       = "23514"
+	pgInvalidText          = "22P02"
+	pgInvalidDatetime      = "22007"
+	pgDatetimeOverflow     = "22008"
+	pgNoDataFound          = "P0002"
+	pgSerializationFailure = "40001"
+)
+
+// Error — ошибка хранилища с категорией Kind (ErrNotFound, ErrConflict, ...).
+// Исходная ошибка драйвера доступна через errors.As, например *pgconn.PgError
+type Error struct {
+	Kind error  // Категория ошибки
+	Msg  string // Описание на русском языке
+	Err  error  // Исходная ошибка, может быть nil
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// Is позволяет проверять категорию через errors.Is(err, ErrNotFound)
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound создает ошибку категории ErrNotFound
+func NotFound(format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Msg: fmt.Sprintf(format, args...)}
+}
+
+// InvalidInput создает ошибку категории ErrInvalidInput
+func InvalidInput(format string, args ...interface{}) error {
+	return &Error{Kind: ErrInvalidInput, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Invalid оборачивает ошибку проверки входных данных (model.ValidationErrors) в ошибку категории ErrInvalidInput.
+// Список полей доступен через errors.As
+func Invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: ErrInvalidInput, Msg: "ошибка проверки входных данных", Err: err}
+}
+
+// wrapError дополняет ошибку err описанием msg и определяет категорию по коду ошибки PostgreSQL.
+// Ошибки без известного кода оборачиваются без категории
+func wrapError(msg string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: pgErrorKind(err), Msg: msg, Err: err}
+}
+
+// pgErrorKind отображает код ошибки PostgreSQL на категорию ошибки хранилища
+func pgErrorKind(err error) error {
+	var domain *Error
+	if errors.As(err, &domain) {
+		return domain.Kind
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if pgErr.ConstraintName == "users_username_key" || strings.Contains(pgErr.Detail, "(username)") {
+			return ErrUsernameTaken
+		}
+		return ErrConflict
+	case pgForeignKeyViolation, pgSerializationFailure:
+		return ErrConflict
+	case pgNotNullViolation, pgCheckViolation, pgInvalidText, pgInvalidDatetime, pgDatetimeOverflow:
+		return ErrInvalidInput
+	case pgNoDataFound:
+		return ErrNotFound
+	}
+	return nil
+}
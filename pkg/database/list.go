@@ -0,0 +1,363 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// SortField задает поле сортировки списков
+type SortField string
+
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByUsername  SortField = "username"
+	SortByFullName  SortField = "full_name" // Только для клиентов и менеджеров
+	SortByHireDate  SortField = "hire_date" // Только для менеджеров
+)
+
+const (
+	// DefaultListLimit используется, если ListOptions.Limit не задан
+	DefaultListLimit = 50
+	// MaxListLimit ограничивает размер одной страницы
+	MaxListLimit = 500
+)
+
+// ListOptions задает фильтры и параметры постраничной выборки
+type ListOptions struct {
+	Limit          int       // Размер страницы. 0 — DefaultListLimit
+	Cursor         string    // Курсор из Page.NextCursor предыдущей страницы
+	SortBy         SortField // Поле сортировки. По умолчанию SortByCreatedAt
+	Descending     bool      // Сортировка по убыванию
+	Role           string    // Фильтр по роли. Учитывается только в ListUsers
+	CreatedFrom    time.Time // Нижняя граница created_at включительно. Нулевое значение — без ограничения
+	CreatedTo      time.Time // Верхняя граница created_at не включительно. Нулевое значение — без ограничения
+	IncludeDeleted bool      // Включать логически удаленные записи
+}
+
+// Page содержит одну страницу списка и курсор следующей страницы.
+// NextCursor пустой, если страница последняя
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// Cursor указывает на последнюю запись страницы. Сортировка всегда дополняется
+// полем id, поэтому позиция однозначна даже при одинаковых значениях поля сортировки
+type Cursor struct {
+	SortBy     SortField `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	Value      string    `json:"v"`
+	ID         string    `json:"id"`
+}
+
+// Encode кодирует курсор в непрозрачную строку для передачи клиенту
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor декодирует курсор и проверяет, что он получен с теми же параметрами сортировки
+func ParseCursor(s string, opts ListOptions) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, &Error{Kind: ErrInvalidInput, Msg: "некорректный курсор", Err: err}
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, &Error{Kind: ErrInvalidInput, Msg: "некорректный курсор", Err: err}
+	}
+	if c.SortBy != opts.SortBy || c.Descending != opts.Descending {
+		return c, InvalidInput("курсор получен с другими параметрами сортировки")
+	}
+	return c, nil
+}
+
+// IsTimeSortField сообщает, что значение поля сортировки хранится как время в формате RFC3339Nano
+func IsTimeSortField(f SortField) bool {
+	return f == SortByCreatedAt || f == SortByHireDate
+}
+
+// Normalize заполняет значения по умолчанию и проверяет, что поле сортировки допустимо
+func (o ListOptions) Normalize(allowed ...SortField) (ListOptions, error) {
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+	if o.Limit > MaxListLimit {
+		o.Limit = MaxListLimit
+	}
+	if o.SortBy == "" {
+		o.SortBy = SortByCreatedAt
+	}
+	for _, f := range allowed {
+		if f == o.SortBy {
+			return o, nil
+		}
+	}
+	return o, InvalidInput("сортировка по полю %s не поддерживается", o.SortBy)
+}
+
+// listQuery собирает SQL-запрос постраничной выборки
+type listQuery struct {
+	selectFrom string               // SELECT ... FROM ... JOIN ...
+	columns    map[SortField]string // Выражения для полей сортировки
+	conditions []string
+	args       []interface{}
+}
+
+func (q *listQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// build добавляет фильтры ListOptions, условие курсора, сортировку и лимит.
+// Запрашивается на одну запись больше, чтобы определить наличие следующей страницы
+func (q *listQuery) build(opts ListOptions) (string, error) {
+	column := q.columns[opts.SortBy]
+
+	if !opts.IncludeDeleted {
+		q.conditions = append(q.conditions, "u.is_deleted = false")
+	}
+	if !opts.CreatedFrom.IsZero() {
+		q.conditions = append(q.conditions, "u.created_at >= "+q.arg(opts.CreatedFrom))
+	}
+	if !opts.CreatedTo.IsZero() {
+		q.conditions = append(q.conditions, "u.created_at < "+q.arg(opts.CreatedTo))
+	}
+
+	if opts.Cursor != "" {
+		cursor, err := ParseCursor(opts.Cursor, opts)
+		if err != nil {
+			return "", err
+		}
+		var value interface{} = cursor.Value
+		if IsTimeSortField(opts.SortBy) {
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return "", &Error{Kind: ErrInvalidInput, Msg: "некорректный курсор", Err: err}
+			}
+			value = t
+		}
+		op := ">"
+		if opts.Descending {
+			op = "<"
+		}
+		q.conditions = append(q.conditions, fmt.Sprintf("(%s, u.id) %s (%s, %s)", column, op, q.arg(value), q.arg(cursor.ID)))
+	}
+
+	query := q.selectFrom
+	if len(q.conditions) > 0 {
+		query += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+	direction := "ASC"
+	if opts.Descending {
+		direction = "DESC"
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, u.id %s LIMIT %s", column, direction, direction, q.arg(opts.Limit+1))
+	return query, nil
+}
+
+// nextPage обрезает лишнюю запись и формирует курсор следующей страницы.
+// key возвращает значение поля сортировки и id записи с индексом i
+func nextPage[T any](items []T, opts ListOptions, key func(i int) (value, id string)) Page[T] {
+	page := Page[T]{Items: items}
+	if len(items) > opts.Limit {
+		page.Items = items[:opts.Limit]
+		value, id := key(opts.Limit - 1)
+		page.NextCursor = Cursor{SortBy: opts.SortBy, Descending: opts.Descending, Value: value, ID: id}.Encode()
+	}
+	return page
+}
+
+// Текстовые поля сортируются побайтово (COLLATE "C"), чтобы порядок не зависел от локали базы
+var (
+	userColumns = map[SortField]string{
+		SortByCreatedAt: "u.created_at",
+		SortByUsername:  `u.username COLLATE "C"`,
+	}
+	clientColumns = map[SortField]string{
+		SortByCreatedAt: "u.created_at",
+		SortByUsername:  `u.username COLLATE "C"`,
+		SortByFullName:  `c.full_name COLLATE "C"`,
+	}
+	managerColumns = map[SortField]string{
+		SortByCreatedAt: "u.created_at",
+		SortByUsername:  `u.username COLLATE "C"`,
+		SortByFullName:  `m.full_name COLLATE "C"`,
+		SortByHireDate:  "m.hire_date",
+	}
+)
+
+// ListUsers возвращает страницу пользователей. Хеши паролей не выбираются
+func (db *Store) ListUsers(ctx context.Context, opts ListOptions) (Page[model.User], error) {
+	opts, err := opts.Normalize(SortByCreatedAt, SortByUsername)
+	if err != nil {
+		return Page[model.User]{}, err
+	}
+
+	q := listQuery{
+		selectFrom: `SELECT u.id, u.username, u.role, u.is_deleted, u.created_at, u.updated_at FROM users u`,
+		columns:    userColumns,
+	}
+	if opts.Role != "" {
+		q.conditions = append(q.conditions, "u.role = "+q.arg(opts.Role))
+	}
+	query, err := q.build(opts)
+	if err != nil {
+		return Page[model.User]{}, err
+	}
+
+	users, err := collect(ctx, db, query, q.args, func(row pgx.Rows) (model.User, error) {
+		var user model.User
+
+		err := row.Scan(&user.ID, &user.Username, &user.Role, &user.IsDeleted, &user.CreatedAt, &user.UpdatedAt)
+		return user, err
+	})
+	if err != nil {
+		return Page[model.User]{}, err
+	}
+
+	return nextPage(users, opts, func(i int) (string, string) {
+		return sortValue(opts.SortBy, users[i].CreatedAt, users[i].Username, ""), users[i].ID
+	}), nil
+}
+
+// ListAdmins возвращает страницу администраторов
+func (db *Store) ListAdmins(ctx context.Context, opts ListOptions) (Page[model.Admin], error) {
+	opts, err := opts.Normalize(SortByCreatedAt, SortByUsername)
+	if err != nil {
+		return Page[model.Admin]{}, err
+	}
+
+	q := listQuery{
+		selectFrom: `SELECT u.id, u.username, a.permissions, u.is_deleted, u.created_at, u.updated_at
+					 FROM admins a JOIN users u ON a.id = u.id`,
+		columns: userColumns,
+	}
+	query, err := q.build(opts)
+	if err != nil {
+		return Page[model.Admin]{}, err
+	}
+
+	admins, err := collect(ctx, db, query, q.args, func(row pgx.Rows) (model.Admin, error) {
+		var admin model.Admin
+
+		err := row.Scan(&admin.ID, &admin.Username, &admin.Permissions, &admin.IsDeleted, &admin.CreatedAt, &admin.UpdatedAt)
+		return admin, err
+	})
+	if err != nil {
+		return Page[model.Admin]{}, err
+	}
+
+	return nextPage(admins, opts, func(i int) (string, string) {
+		return sortValue(opts.SortBy, admins[i].CreatedAt, admins[i].Username, ""), admins[i].ID
+	}), nil
+}
+
+// ListClients возвращает страницу клиентов
+func (db *Store) ListClients(ctx context.Context, opts ListOptions) (Page[model.Client], error) {
+	opts, err := opts.Normalize(SortByCreatedAt, SortByUsername, SortByFullName)
+	if err != nil {
+		return Page[model.Client]{}, err
+	}
+
+	q := listQuery{
+		selectFrom: `SELECT u.id, u.username, c.full_name, c.phone_number, u.is_deleted, u.created_at, u.updated_at
+					 FROM clients c JOIN users u ON c.id = u.id`,
+		columns: clientColumns,
+	}
+	query, err := q.build(opts)
+	if err != nil {
+		return Page[model.Client]{}, err
+	}
+
+	clients, err := collect(ctx, db, query, q.args, func(row pgx.Rows) (model.Client, error) {
+		var client model.Client
+
+		err := row.Scan(&client.ID, &client.Username, &client.FullName, &client.PhoneNumber, &client.IsDeleted, &client.CreatedAt, &client.UpdatedAt)
+		return client, err
+	})
+	if err != nil {
+		return Page[model.Client]{}, err
+	}
+
+	return nextPage(clients, opts, func(i int) (string, string) {
+		return sortValue(opts.SortBy, clients[i].CreatedAt, clients[i].Username, clients[i].FullName), clients[i].ID
+	}), nil
+}
+
+// ListManagers возвращает страницу менеджеров
+func (db *Store) ListManagers(ctx context.Context, opts ListOptions) (Page[model.Manager], error) {
+	opts, err := opts.Normalize(SortByCreatedAt, SortByUsername, SortByFullName, SortByHireDate)
+	if err != nil {
+		return Page[model.Manager]{}, err
+	}
+
+	q := listQuery{
+		selectFrom: `SELECT u.id, u.username, m.full_name, m.hire_date, u.is_deleted, u.created_at, u.updated_at
+					 FROM managers m JOIN users u ON m.id = u.id`,
+		columns: managerColumns,
+	}
+	query, err := q.build(opts)
+	if err != nil {
+		return Page[model.Manager]{}, err
+	}
+
+	managers, err := collect(ctx, db, query, q.args, func(row pgx.Rows) (model.Manager, error) {
+		var manager model.Manager
+
+		err := row.Scan(&manager.ID, &manager.Username, &manager.FullName, &manager.HireDate, &manager.IsDeleted, &manager.CreatedAt, &manager.UpdatedAt)
+		return manager, err
+	})
+	if err != nil {
+		return Page[model.Manager]{}, err
+	}
+
+	return nextPage(managers, opts, func(i int) (string, string) {
+		if opts.SortBy == SortByHireDate {
+			return managers[i].HireDate.Time(time.UTC).Format(time.RFC3339Nano), managers[i].ID
+		}
+		return sortValue(opts.SortBy, managers[i].CreatedAt, managers[i].Username, managers[i].FullName), managers[i].ID
+	}), nil
+}
+
+// sortValue возвращает значение поля сортировки для курсора
+func sortValue(f SortField, createdAt time.Time, username, fullName string) string {
+	switch f {
+	case SortByUsername:
+		return username
+	case SortByFullName:
+		return fullName
+	default:
+		return createdAt.Format(time.RFC3339Nano)
+	}
+}
+
+// collect выполняет запрос и сканирует все строки функцией scan
+func collect[T any](ctx context.Context, db *Store, query string, args []interface{}, scan func(pgx.Rows) (T, error)) ([]T, error) {
+	rows, err := db.conn().Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError("ошибка выполнения запроса", err)
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, wrapError("ошибка чтения строки", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("ошибка чтения результата запроса", err)
+	}
+
+	return items, nil
+}
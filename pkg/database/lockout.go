@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LockoutRule задает, после скольких неудачных попыток входа и на какое время блокируется ключ
+type LockoutRule struct {
+	MaxFailures int           // Число неудачных попыток подряд до первой блокировки. 0 — не блокировать
+	BaseLockout time.Duration // Длительность первой блокировки, каждая следующая вдвое длиннее
+	MaxLockout  time.Duration // Верхняя граница длительности блокировки. 0 — MaxLockoutLimit
+	Window      time.Duration // Счетчик сбрасывается, если неудачных попыток не было дольше Window. 0 — не сбрасывается
+}
+
+// MaxLockoutLimit — наибольшая длительность блокировки. Ограничивает удвоение, если MaxLockout не задан
+const MaxLockoutLimit = 365 * 24 * time.Hour
+
+// Duration возвращает длительность блокировки после failures неудачных попыток подряд или 0
+func (r LockoutRule) Duration(failures int) time.Duration {
+	if r.MaxFailures <= 0 || failures < r.MaxFailures || r.BaseLockout <= 0 {
+		return 0
+	}
+	limit := r.MaxLockout
+	if limit <= 0 || limit > MaxLockoutLimit {
+		limit = MaxLockoutLimit
+	}
+	// Удвоение останавливается на границе, поэтому d не переполняется при любом числе попыток
+	d := r.BaseLockout
+	for i := r.MaxFailures; i < failures && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return d
+}
+
+// UserLockoutKey возвращает ключ учета попыток входа для имени пользователя.
+// Попытки учитываются и для несуществующих имен, чтобы блокировка не выдавала наличие учетной записи
+func UserLockoutKey(username string) string {
+	return "user:" + username
+}
+
+// IPLockoutKey возвращает ключ учета попыток входа для IP-адреса клиента
+func IPLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginLockedUntil возвращает наибольшее время окончания действующей блокировки среди ключей.
+// Нулевое время — вход не заблокирован
+func (db *Store) LoginLockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	var lockedUntil *time.Time
+	query := `SELECT max(locked_until) FROM login_attempts WHERE key = ANY($1) AND locked_until > now()`
+	if err := db.conn().QueryRow(ctx, query, keys).Scan(&lockedUntil); err != nil {
+		return time.Time{}, wrapError("ошибка проверки блокировки входа", err)
+	}
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+	return *lockedUntil, nil
+}
+
+// RegisterLoginFailure учитывает неудачную попытку входа по ключу и, если число попыток
+// достигло rule.MaxFailures, блокирует ключ. Возвращает время окончания новой блокировки или нулевое время
+func (db *Store) RegisterLoginFailure(ctx context.Context, key string, rule LockoutRule) (time.Time, error) {
+	// Счетчик и блокировка изменяются под одной блокировкой строки
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.rollback(ctx)
+
+	var failures int
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, now())
+			  ON CONFLICT (key) DO UPDATE SET
+			      failures = CASE
+			          WHEN $2::interval > interval '0' AND login_attempts.last_failure_at < now() - $2::interval THEN 1
+			          ELSE login_attempts.failures + 1
+			      END,
+			      last_failure_at = now()
+			  RETURNING failures`
+	if err := tx.conn().QueryRow(ctx, query, key, rule.Window).Scan(&failures); err != nil {
+		return time.Time{}, wrapError("ошибка учета неудачной попытки входа", err)
+	}
+
+	var lockedUntil time.Time
+	if d := rule.Duration(failures); d > 0 {
+		query := `UPDATE login_attempts SET locked_until = now() + $2::interval WHERE key = $1 RETURNING locked_until`
+		if err := tx.conn().QueryRow(ctx, query, key, d).Scan(&lockedUntil); err != nil {
+			return time.Time{}, wrapError("ошибка блокировки входа", err)
+		}
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return time.Time{}, err
+	}
+	return lockedUntil, nil
+}
+
+// ResetLoginFailures сбрасывает счетчик неудачных попыток и блокировку ключа
+func (db *Store) ResetLoginFailures(ctx context.Context, key string) error {
+	if _, err := db.conn().Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return wrapError("ошибка сброса неудачных попыток входа", err)
+	}
+	return nil
+}
+
+// PurgeExpiredLoginAttempts удаляет счетчики неудачных попыток без действующей блокировки,
+// последняя неудачная попытка по которым была раньше, чем olderThan назад, и возвращает число удаленных записей.
+// olderThan обычно равен наибольшему LockoutRule.Window: более старые счетчики все равно были бы сброшены
+func (db *Store) PurgeExpiredLoginAttempts(ctx context.Context, olderThan time.Duration) (int, error) {
+	query := `DELETE FROM login_attempts
+			  WHERE last_failure_at < now() - $1::interval AND (locked_until IS NULL OR locked_until <= now())`
+	tag, err := db.conn().Exec(ctx, query, olderThan)
+	if err != nil {
+		return 0, wrapError("ошибка удаления устаревших неудачных попыток входа", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// UnlockUser снимает блокировку входа по имени пользователя до истечения ее срока.
+// Блокировки по IP-адресам не снимаются
+func (db *Store) UnlockUser(ctx context.Context, userID string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	var username string
+	err = tx.conn().QueryRow(ctx, `SELECT username FROM users WHERE id = $1 AND is_deleted = false`, userID).Scan(&username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return NotFound("пользователь с ID %s не найден", userID)
+		}
+		return wrapError("ошибка получения пользователя", err)
+	}
+
+	if err := tx.ResetLoginFailures(ctx, UserLockoutKey(username)); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Блокировка входа снята администратором")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
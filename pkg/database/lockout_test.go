@@ -0,0 +1,35 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutRuleDuration(t *testing.T) {
+	unlimited := LockoutRule{MaxFailures: 5, BaseLockout: time.Minute}
+	limited := LockoutRule{MaxFailures: 5, BaseLockout: time.Minute, MaxLockout: time.Hour}
+	tests := []struct {
+		name     string
+		rule     LockoutRule
+		failures int
+		want     time.Duration
+	}{
+		{"до порога", limited, 4, 0},
+		{"первая блокировка", limited, 5, time.Minute},
+		{"удвоение", limited, 7, 4 * time.Minute},
+		{"граница MaxLockout", limited, 12, time.Hour},
+		{"много попыток с MaxLockout", limited, 1000, time.Hour},
+		{"удвоение без MaxLockout", unlimited, 8, 8 * time.Minute},
+		{"без MaxLockout не переполняется", unlimited, 38, MaxLockoutLimit},
+		{"без MaxLockout много попыток", unlimited, 75, MaxLockoutLimit},
+		{"блокировка отключена", LockoutRule{BaseLockout: time.Minute}, 100, 0},
+		{"нулевая длительность", LockoutRule{MaxFailures: 1}, 1 << 30, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Duration(tt.failures); got != tt.want {
+				t.Errorf("Duration(%d) = %v, ожидалось %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
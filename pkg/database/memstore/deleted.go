@@ -0,0 +1,139 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+func (s *Store) RestoreUser(ctx context.Context, userID string) (model.User, error) {
+	if err := checkID(userID); err != nil {
+		return model.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || !u.isDeleted {
+		return model.User{}, database.NotFound("удаленный пользователь с ID %s не найден", userID)
+	}
+	u.isDeleted = false
+	u.updatedAt = s.now().UTC()
+
+	if err := s.logActionLocked(userID, "Пользователь был восстановлен"); err != nil {
+		return u.toModel(), fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return u.toModel(), nil
+}
+
+func (s *Store) ListDeletedUsers(ctx context.Context) ([]model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deleted := []*userRecord{}
+	for _, u := range s.users {
+		if u.isDeleted {
+			deleted = append(deleted, u)
+		}
+	}
+	// Сортируем так же, как ORDER BY updated_at DESC, id
+	sort.Slice(deleted, func(i, j int) bool {
+		if !deleted[i].updatedAt.Equal(deleted[j].updatedAt) {
+			return deleted[i].updatedAt.After(deleted[j].updatedAt)
+		}
+		return deleted[i].id < deleted[j].id
+	})
+
+	users := make([]model.User, 0, len(deleted))
+	for _, u := range deleted {
+		user := u.toModel()
+		user.PasswordHash = ""
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (s *Store) PurgeUser(ctx context.Context, userID string, opts database.PurgeOptions) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || !u.isDeleted {
+		return database.NotFound("удаленный пользователь с ID %s не найден", userID)
+	}
+	s.purgeLocked(u, opts)
+	return nil
+}
+
+func (s *Store) PurgeDeletedOlderThan(ctx context.Context, age time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-age)
+	purged := 0
+	for _, u := range s.users {
+		if u.isDeleted && u.updatedAt.Before(cutoff) {
+			s.purgeLocked(u, database.PurgeOptions{AnonymizeLogs: true})
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// clientInfo — сведения о клиенте в записи журнала, см. database.ClientInfoPattern
+var clientInfo = regexp.MustCompile(database.ClientInfoPattern)
+
+// purgeLocked физически удаляет пользователя и его журнал. Вызывается под s.mu
+func (s *Store) purgeLocked(u *userRecord, opts database.PurgeOptions) {
+	name := regexp.MustCompile(database.UsernamePattern(u.username))
+	logs := s.logs[:0]
+	for _, l := range s.logs {
+		if l.UserID == u.id {
+			if !opts.AnonymizeLogs {
+				continue
+			}
+			l.UserID = ""
+			l.Action = clientInfo.ReplaceAllString(name.ReplaceAllString(name.ReplaceAllString(l.Action, "${1}***${2}"), "${1}***${2}"), "")
+		}
+		logs = append(logs, l)
+	}
+	s.logs = logs
+	delete(s.loginAttempts, database.UserLockoutKey(u.username))
+
+	delete(s.admins, u.id)
+	delete(s.clients, u.id)
+	delete(s.managers, u.id)
+	delete(s.users, u.id)
+	delete(s.mfa, u.id)
+	for hash, t := range s.refreshTokens {
+		if t.UserID == u.id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for hash, t := range s.revokedTokens {
+		if t.userID == u.id {
+			delete(s.revokedTokens, hash)
+		}
+	}
+	for hash, t := range s.resetTokens {
+		if t.userID == u.id {
+			delete(s.resetTokens, hash)
+		}
+	}
+	for i, id := range s.order {
+		if id == u.id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+// sortKey — значение поля сортировки записи. Для полей-времени используется t, для текстовых — s
+type sortKey struct {
+	t  time.Time
+	s  string
+	id string
+}
+
+func (k sortKey) less(other sortKey, timeField bool) bool {
+	if timeField && !k.t.Equal(other.t) {
+		return k.t.Before(other.t)
+	}
+	if !timeField && k.s != other.s {
+		return k.s < other.s
+	}
+	return k.id < other.id
+}
+
+func (k sortKey) value(timeField bool) string {
+	if timeField {
+		return k.t.Format(time.RFC3339Nano)
+	}
+	return k.s
+}
+
+// listLocked отбирает пользователей с ролью role по фильтрам opts и возвращает страницу записей
+// в порядке сортировки и курсор следующей страницы. Вызывается под s.mu
+func (s *Store) listLocked(role string, opts database.ListOptions, key func(u *userRecord) sortKey) ([]*userRecord, string, error) {
+	timeField := database.IsTimeSortField(opts.SortBy)
+
+	var after *sortKey
+	if opts.Cursor != "" {
+		cursor, err := database.ParseCursor(opts.Cursor, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		k := sortKey{s: cursor.Value, id: cursor.ID}
+		if timeField {
+			if k.t, err = time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+				return nil, "", &database.Error{Kind: database.ErrInvalidInput, Msg: "некорректный курсор", Err: err}
+			}
+		}
+		after = &k
+	}
+
+	type entry struct {
+		u *userRecord
+		k sortKey
+	}
+	var entries []entry
+	for _, u := range s.users {
+		if role != "" && u.role != role {
+			continue
+		}
+		if u.isDeleted && !opts.IncludeDeleted {
+			continue
+		}
+		if !opts.CreatedFrom.IsZero() && u.createdAt.Before(opts.CreatedFrom) {
+			continue
+		}
+		if !opts.CreatedTo.IsZero() && !u.createdAt.Before(opts.CreatedTo) {
+			continue
+		}
+		k := key(u)
+		if after != nil {
+			if opts.Descending && !k.less(*after, timeField) {
+				continue
+			}
+			if !opts.Descending && !after.less(k, timeField) {
+				continue
+			}
+		}
+		entries = append(entries, entry{u: u, k: k})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if opts.Descending {
+			return entries[j].k.less(entries[i].k, timeField)
+		}
+		return entries[i].k.less(entries[j].k, timeField)
+	})
+
+	var next string
+	if len(entries) > opts.Limit {
+		entries = entries[:opts.Limit]
+		last := entries[opts.Limit-1].k
+		next = database.Cursor{SortBy: opts.SortBy, Descending: opts.Descending, Value: last.value(timeField), ID: last.id}.Encode()
+	}
+
+	users := make([]*userRecord, len(entries))
+	for i, e := range entries {
+		users[i] = e.u
+	}
+	return users, next, nil
+}
+
+// userSortKey возвращает ключ сортировки по общим полям пользователя
+func userSortKey(f database.SortField) func(u *userRecord) sortKey {
+	return func(u *userRecord) sortKey {
+		if f == database.SortByUsername {
+			return sortKey{s: u.username, id: u.id}
+		}
+		return sortKey{t: u.createdAt, id: u.id}
+	}
+}
+
+func (s *Store) ListUsers(ctx context.Context, opts database.ListOptions) (database.Page[model.User], error) {
+	opts, err := opts.Normalize(database.SortByCreatedAt, database.SortByUsername)
+	if err != nil {
+		return database.Page[model.User]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, next, err := s.listLocked(opts.Role, opts, userSortKey(opts.SortBy))
+	if err != nil {
+		return database.Page[model.User]{}, err
+	}
+
+	page := database.Page[model.User]{Items: []model.User{}, NextCursor: next}
+	for _, u := range records {
+		user := u.toModel()
+		user.PasswordHash = ""
+		page.Items = append(page.Items, user)
+	}
+	return page, nil
+}
+
+func (s *Store) ListAdmins(ctx context.Context, opts database.ListOptions) (database.Page[model.Admin], error) {
+	opts, err := opts.Normalize(database.SortByCreatedAt, database.SortByUsername)
+	if err != nil {
+		return database.Page[model.Admin]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, next, err := s.listLocked("admin", opts, userSortKey(opts.SortBy))
+	if err != nil {
+		return database.Page[model.Admin]{}, err
+	}
+
+	page := database.Page[model.Admin]{Items: []model.Admin{}, NextCursor: next}
+	for _, u := range records {
+		admin, err := s.adminModelLocked(u)
+		if err != nil {
+			return database.Page[model.Admin]{}, err
+		}
+		page.Items = append(page.Items, admin)
+	}
+	return page, nil
+}
+
+func (s *Store) ListClients(ctx context.Context, opts database.ListOptions) (database.Page[model.Client], error) {
+	opts, err := opts.Normalize(database.SortByCreatedAt, database.SortByUsername, database.SortByFullName)
+	if err != nil {
+		return database.Page[model.Client]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := userSortKey(opts.SortBy)
+	if opts.SortBy == database.SortByFullName {
+		key = func(u *userRecord) sortKey { return sortKey{s: s.clients[u.id].fullName, id: u.id} }
+	}
+	records, next, err := s.listLocked("client", opts, key)
+	if err != nil {
+		return database.Page[model.Client]{}, err
+	}
+
+	page := database.Page[model.Client]{Items: []model.Client{}, NextCursor: next}
+	for _, u := range records {
+		page.Items = append(page.Items, s.clientModelLocked(u))
+	}
+	return page, nil
+}
+
+func (s *Store) ListManagers(ctx context.Context, opts database.ListOptions) (database.Page[model.Manager], error) {
+	opts, err := opts.Normalize(database.SortByCreatedAt, database.SortByUsername, database.SortByFullName, database.SortByHireDate)
+	if err != nil {
+		return database.Page[model.Manager]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := userSortKey(opts.SortBy)
+	switch opts.SortBy {
+	case database.SortByFullName:
+		key = func(u *userRecord) sortKey { return sortKey{s: s.managers[u.id].fullName, id: u.id} }
+	case database.SortByHireDate:
+		key = func(u *userRecord) sortKey { return sortKey{t: s.managers[u.id].hireDate.Time(time.UTC), id: u.id} }
+	}
+	records, next, err := s.listLocked("manager", opts, key)
+	if err != nil {
+		return database.Page[model.Manager]{}, err
+	}
+
+	page := database.Page[model.Manager]{Items: []model.Manager{}, NextCursor: next}
+	for _, u := range records {
+		page.Items = append(page.Items, s.managerModelLocked(u))
+	}
+	return page, nil
+}
@@ -0,0 +1,97 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+)
+
+type loginAttemptRecord struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+func (s *Store) LoginLockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	var lockedUntil time.Time
+	for _, key := range keys {
+		a, ok := s.loginAttempts[key]
+		if ok && a.lockedUntil.After(now) && a.lockedUntil.After(lockedUntil) {
+			lockedUntil = a.lockedUntil
+		}
+	}
+	return lockedUntil, nil
+}
+
+func (s *Store) RegisterLoginFailure(ctx context.Context, key string, rule database.LockoutRule) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	a, ok := s.loginAttempts[key]
+	if !ok {
+		a = &loginAttemptRecord{}
+		s.loginAttempts[key] = a
+	}
+	if rule.Window > 0 && a.lastFailureAt.Before(now.Add(-rule.Window)) {
+		a.failures = 0
+	}
+	a.failures++
+	a.lastFailureAt = now
+
+	d := rule.Duration(a.failures)
+	if d == 0 {
+		return time.Time{}, nil
+	}
+	a.lockedUntil = now.Add(d)
+	return a.lockedUntil, nil
+}
+
+func (s *Store) ResetLoginFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginAttempts, key)
+	return nil
+}
+
+func (s *Store) PurgeExpiredLoginAttempts(ctx context.Context, olderThan time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	purged := 0
+	for key, a := range s.loginAttempts {
+		if a.lastFailureAt.Before(now.Add(-olderThan)) && !a.lockedUntil.After(now) {
+			delete(s.loginAttempts, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *Store) UnlockUser(ctx context.Context, userID string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(userID, "")
+	if !ok {
+		return database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	delete(s.loginAttempts, database.UserLockoutKey(u.username))
+
+	if err := s.logActionLocked(userID, "Блокировка входа снята администратором"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+// Package memstore содержит реализацию репозиториев crmlib в памяти процесса.
+// Используется в тестах и локальных демо-окружениях вместо PostgreSQL
+package memstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+type userRecord struct {
+	id           string
+	username     string
+	passwordHash string
+	role         string
+	isDeleted    bool
+	createdAt    time.Time
+	updatedAt    time.Time
+
+	tokensValidAfter time.Time // Токены, выданные раньше, недействительны
+}
+
+type clientRecord struct {
+	fullName    string
+	phoneNumber string
+}
+
+type managerRecord struct {
+	fullName string
+	hireDate model.Date
+}
+
+// Store хранит все сущности в памяти. Безопасен для конкурентного использования
+type Store struct {
+	mu       sync.RWMutex
+	users    map[string]*userRecord
+	order    []string // Порядок создания пользователей
+	admins   map[string][]byte
+	clients  map[string]*clientRecord
+	managers map[string]*managerRecord
+	logs     []model.UserLog
+	now      func() time.Time
+
+	resetTokens   map[string]*resetTokenRecord   // По хешу токена
+	loginAttempts map[string]*loginAttemptRecord // По ключу database.UserLockoutKey или database.IPLockoutKey
+	mfa           map[string]*mfaRecord          // По ID пользователя
+	refreshTokens map[string]*model.RefreshToken // По хешу jti
+	revokedTokens map[string]*revokedTokenRecord // По хешу jti
+}
+
+// Проверяем на этапе компиляции, что Store реализует все репозитории
+var _ database.Repos = (*Store)(nil)
+
+// New создает пустое хранилище в памяти
+func New() *Store {
+	return &Store{
+		users:    make(map[string]*userRecord),
+		admins:   make(map[string][]byte),
+		clients:  make(map[string]*clientRecord),
+		managers: make(map[string]*managerRecord),
+		now:      time.Now,
+
+		resetTokens:   make(map[string]*resetTokenRecord),
+		loginAttempts: make(map[string]*loginAttemptRecord),
+		mfa:           make(map[string]*mfaRecord),
+		refreshTokens: make(map[string]*model.RefreshToken),
+		revokedTokens: make(map[string]*revokedTokenRecord),
+	}
+}
+
+// Logs возвращает копию журнала действий пользователей
+func (s *Store) Logs() []model.UserLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	logs := make([]model.UserLog, len(s.logs))
+	copy(logs, s.logs)
+	return logs
+}
+
+func (s *Store) LogAction(ctx context.Context, userID, action string) error {
+	if userID != "" {
+		if err := checkID(userID); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logActionLocked(userID, action)
+}
+
+// logActionLocked добавляет запись в журнал. Пустой userID — запись без привязки к пользователю. Вызывается под s.mu
+func (s *Store) logActionLocked(userID, action string) error {
+	if _, ok := s.users[userID]; !ok && userID != "" {
+		// Повторяем поведение внешнего ключа user_logs.user_id
+		return &database.Error{Kind: database.ErrConflict, Msg: fmt.Sprintf("ошибка записи лога для пользователя с ID %s: пользователь не существует", userID)}
+	}
+
+	s.logs = append(s.logs, model.UserLog{
+		ID:        newID(),
+		UserID:    userID,
+		Action:    action,
+		Timestamp: s.now().UTC(),
+	})
+	return nil
+}
+
+// createUserLocked создает запись в таблице пользователей. Вызывается под s.mu
+func (s *Store) createUserLocked(username, passwordHash, role string) (*userRecord, error) {
+	// Имя пользователя уникально среди всех записей, включая логически удаленные
+	for _, u := range s.users {
+		if u.username == username {
+			return nil, &database.Error{Kind: database.ErrUsernameTaken, Msg: fmt.Sprintf("пользователь с именем %s уже существует", username)}
+		}
+	}
+
+	now := s.now().UTC()
+	u := &userRecord{
+		id:           newID(),
+		username:     username,
+		passwordHash: passwordHash,
+		role:         role,
+		createdAt:    now,
+		updatedAt:    now,
+	}
+	s.users[u.id] = u
+	s.order = append(s.order, u.id)
+	return u, nil
+}
+
+// activeUserLocked возвращает неудаленного пользователя с указанной ролью. Вызывается под s.mu
+func (s *Store) activeUserLocked(id, role string) (*userRecord, bool) {
+	u, ok := s.users[id]
+	if !ok || u.isDeleted || (role != "" && u.role != role) {
+		return nil, false
+	}
+	return u, true
+}
+
+func (s *Store) CreateAdmin(ctx context.Context, username, password string, permissions map[string]interface{}) (string, error) {
+	input := model.CreateAdminInput{Username: username, Password: password, Permissions: permissions}
+	if err := input.Validate(); err != nil {
+		return "", database.Invalid(err)
+	}
+
+	// Храним права в JSON, как колонка permissions типа JSONB
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return "", &database.Error{Kind: database.ErrInvalidInput, Msg: "ошибка преобразования permissions в JSON", Err: err}
+	}
+	passwordHash, err := database.PreparePassword(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.createUserLocked(username, passwordHash, "admin")
+	if err != nil {
+		return "", err
+	}
+	s.admins[u.id] = permissionsJSON
+
+	if err := s.logActionLocked(u.id, fmt.Sprintf("Администратор %s был создан", username)); err != nil {
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return u.id, nil
+}
+
+func (s *Store) CreateClient(ctx context.Context, username, password, fullName, phoneNumber string) (string, error) {
+	input := model.CreateClientInput{Username: username, Password: password, FullName: fullName, PhoneNumber: phoneNumber}
+	if err := input.Validate(); err != nil {
+		return "", database.Invalid(err)
+	}
+	passwordHash, err := database.PreparePassword(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.createUserLocked(username, passwordHash, "client")
+	if err != nil {
+		return "", err
+	}
+	s.clients[u.id] = &clientRecord{fullName: fullName, phoneNumber: phoneNumber}
+
+	if err := s.logActionLocked(u.id, fmt.Sprintf("Клиент %s был создан", username)); err != nil {
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return u.id, nil
+}
+
+func (s *Store) CreateManager(ctx context.Context, username, password, fullName string, hireDate model.Date) (string, error) {
+	input := model.CreateManagerInput{Username: username, Password: password, FullName: fullName, HireDate: hireDate}
+	if err := input.Validate(); err != nil {
+		return "", database.Invalid(err)
+	}
+	passwordHash, err := database.PreparePassword(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.createUserLocked(username, passwordHash, "manager")
+	if err != nil {
+		return "", err
+	}
+	s.managers[u.id] = &managerRecord{fullName: fullName, hireDate: hireDate}
+
+	if err := s.logActionLocked(u.id, fmt.Sprintf("Менеджер %s был создан", username)); err != nil {
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return u.id, nil
+}
+
+// deleteLocked помечает пользователя с ролью role как удаленного. Вызывается под s.mu
+func (s *Store) deleteLocked(id, role, notFound, action string) error {
+	u, ok := s.activeUserLocked(id, role)
+	if !ok {
+		return database.NotFound(notFound, id)
+	}
+	u.isDeleted = true
+	u.updatedAt = s.now().UTC()
+
+	if err := s.logActionLocked(id, action); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteAdmin(ctx context.Context, adminID string) error {
+	if err := checkID(adminID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteLocked(adminID, "admin", "администратор с ID %s не найден", "Администратор был логически удален")
+}
+
+func (s *Store) DeleteClient(ctx context.Context, clientID string) error {
+	if err := checkID(clientID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteLocked(clientID, "client", "клиент с ID %s не найден", "Клиент был логически удален")
+}
+
+func (s *Store) DeleteManager(ctx context.Context, managerID string) error {
+	if err := checkID(managerID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.deleteLocked(managerID, "manager", "менеджер с ID %s не найден", "Менеджер был логически удален"); err != nil {
+		return err
+	}
+	// Токены удаленного менеджера становятся недействительными сразу, а не по истечении срока
+	s.revokeAllTokensLocked(s.users[managerID])
+	return nil
+}
+
+func (s *Store) GetAllUsers(ctx context.Context) ([]model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []model.User{}
+	for _, id := range s.order {
+		u := s.users[id]
+		if u.isDeleted {
+			continue
+		}
+		// GetAllUsers в PostgreSQL не выбирает password_hash
+		user := u.toModel()
+		user.PasswordHash = ""
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, userID string) (model.User, error) {
+	if err := checkID(userID); err != nil {
+		return model.User{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUserLocked(userID, "")
+	if !ok {
+		return model.User{}, database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	return u.toModel(), nil
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.username == username && !u.isDeleted {
+			return u.toModel(), nil
+		}
+	}
+	return model.User{}, database.NotFound("пользователь с именем %s не найден", username)
+}
+
+func (s *Store) GetAdminByID(ctx context.Context, adminID string) (model.Admin, error) {
+	if err := checkID(adminID); err != nil {
+		return model.Admin{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUserLocked(adminID, "admin")
+	if !ok {
+		return model.Admin{}, database.NotFound("администратор с ID %s не найден", adminID)
+	}
+
+	return s.adminModelLocked(u)
+}
+
+func (s *Store) GetClientByID(ctx context.Context, clientID string) (model.Client, error) {
+	if err := checkID(clientID); err != nil {
+		return model.Client{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUserLocked(clientID, "client")
+	if !ok {
+		return model.Client{}, database.NotFound("клиент с ID %s не найден", clientID)
+	}
+
+	return s.clientModelLocked(u), nil
+}
+
+func (s *Store) GetManagerByID(ctx context.Context, managerID string) (model.Manager, error) {
+	if err := checkID(managerID); err != nil {
+		return model.Manager{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUserLocked(managerID, "manager")
+	if !ok {
+		return model.Manager{}, database.NotFound("менеджер с ID %s не найден", managerID)
+	}
+
+	return s.managerModelLocked(u), nil
+}
+
+func (u *userRecord) toModel() model.User {
+	return model.User{
+		ID:           u.id,
+		Username:     u.username,
+		PasswordHash: u.passwordHash,
+		Role:         u.role,
+		IsDeleted:    u.isDeleted,
+		CreatedAt:    u.createdAt,
+		UpdatedAt:    u.updatedAt,
+	}
+}
+
+func (s *Store) adminModelLocked(u *userRecord) (model.Admin, error) {
+	admin := model.Admin{
+		ID:        u.id,
+		Username:  u.username,
+		IsDeleted: u.isDeleted,
+		CreatedAt: u.createdAt,
+		UpdatedAt: u.updatedAt,
+	}
+	if err := json.Unmarshal(s.admins[u.id], &admin.Permissions); err != nil {
+		return model.Admin{}, err
+	}
+	return admin, nil
+}
+
+func (s *Store) clientModelLocked(u *userRecord) model.Client {
+	c := s.clients[u.id]
+	return model.Client{
+		ID:          u.id,
+		Username:    u.username,
+		FullName:    c.fullName,
+		PhoneNumber: c.phoneNumber,
+		IsDeleted:   u.isDeleted,
+		CreatedAt:   u.createdAt,
+		UpdatedAt:   u.updatedAt,
+	}
+}
+
+func (s *Store) managerModelLocked(u *userRecord) model.Manager {
+	m := s.managers[u.id]
+	return model.Manager{
+		ID:        u.id,
+		Username:  u.username,
+		FullName:  m.fullName,
+		HireDate:  m.hireDate,
+		IsDeleted: u.isDeleted,
+		CreatedAt: u.createdAt,
+		UpdatedAt: u.updatedAt,
+	}
+}
+
+// newID генерирует UUID версии 4, как gen_random_uuid() в PostgreSQL
+// checkID повторяет ошибку PostgreSQL для идентификатора, который нельзя привести к типу uuid
+func checkID(id string) error {
+	if !isUUID(id) {
+		return database.InvalidInput("некорректный идентификатор %q", id)
+	}
+	return nil
+}
+
+// isUUID сообщает, записан ли id в формате uuid: 32 шестнадцатеричные цифры, с дефисами после 8, 12, 16 и 20 цифр или без них
+func isUUID(id string) bool {
+	if len(id) == 36 {
+		if id[8] != '-' || id[13] != '-' || id[18] != '-' || id[23] != '-' {
+			return false
+		}
+		id = strings.ReplaceAll(id, "-", "")
+	}
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
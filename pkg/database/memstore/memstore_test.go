@@ -0,0 +1,91 @@
+package memstore_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/database/conformance"
+	"github.com/Maden-in-haven/crmlib/pkg/database/memstore"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) database.Repos { return memstore.New() })
+}
+
+func TestPurgeUserScrubsClientInfo(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+
+	id, err := s.CreateClient(ctx, "scrubbed", "secret-password", "Клиент", "+79990000030")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if err := s.LogAction(ctx, id, "Неудачная попытка входа scrubbed (IP: 192.0.2.1, User-Agent: curl/8.0)"); err != nil {
+		t.Fatalf("LogAction: %v", err)
+	}
+	if err := s.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if err := s.PurgeUser(ctx, id, database.PurgeOptions{AnonymizeLogs: true}); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+
+	found := false
+	for _, l := range s.Logs() {
+		if l.UserID != "" {
+			continue
+		}
+		if strings.Contains(l.Action, "scrubbed") || strings.Contains(l.Action, "192.0.2.1") || strings.Contains(l.Action, "curl") {
+			t.Errorf("обезличенная запись содержит сведения о пользователе: %q", l.Action)
+		}
+		if l.Action == "Неудачная попытка входа ***" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("обезличенная запись о попытке входа не найдена: %+v", s.Logs())
+	}
+}
+
+func TestPurgeUserAnonymizesWholeUsername(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+
+	id, err := s.CreateAdmin(ctx, "adm", "secret-password", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+	actions := map[string]string{
+		"Права администратора обновлены: permissions": "Права администратора обновлены: permissions",
+		"Вход adm adm на устройстве \"adm\"":          "Вход *** *** на устройстве \"***\"",
+		"adm_2 и admin не совпадают с adm":            "adm_2 и admin не совпадают с ***",
+	}
+	for action := range actions {
+		if err := s.LogAction(ctx, id, action); err != nil {
+			t.Fatalf("LogAction: %v", err)
+		}
+	}
+	if err := s.DeleteAdmin(ctx, id); err != nil {
+		t.Fatalf("DeleteAdmin: %v", err)
+	}
+	if err := s.PurgeUser(ctx, id, database.PurgeOptions{AnonymizeLogs: true}); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+
+	got := map[string]bool{}
+	for _, l := range s.Logs() {
+		if l.UserID == "" {
+			got[l.Action] = true
+		}
+	}
+	if !got["Администратор *** был создан"] {
+		t.Errorf("запись о создании не обезличена: %v", got)
+	}
+	for action, want := range actions {
+		if !got[want] {
+			t.Errorf("%q: ожидалось %q, записи: %v", action, want, got)
+		}
+	}
+}
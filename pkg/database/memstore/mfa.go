@@ -0,0 +1,192 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+type mfaRecord struct {
+	secret        string
+	confirmedAt   time.Time
+	lastUsedStep  int64
+	createdAt     time.Time
+	recoveryCodes map[string]bool // Хеш кода -> использован
+}
+
+func (s *Store) GetMFA(ctx context.Context, userID string) (model.MFA, error) {
+	if err := checkID(userID); err != nil {
+		return model.MFA{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.mfaLocked(userID)
+	if !ok {
+		return model.MFA{}, database.NotFound("двухфакторная аутентификация пользователя с ID %s не подключена", userID)
+	}
+	mfa := model.MFA{
+		UserID:       userID,
+		Secret:       m.secret,
+		LastUsedStep: m.lastUsedStep,
+		CreatedAt:    m.createdAt,
+	}
+	if !m.confirmedAt.IsZero() {
+		mfa.ConfirmedAt = timeRef(m.confirmedAt)
+	}
+	return mfa, nil
+}
+
+func (s *Store) SaveMFASecret(ctx context.Context, userID, secret string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activeUserLocked(userID, ""); !ok {
+		return database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	if m, ok := s.mfa[userID]; ok && !m.confirmedAt.IsZero() {
+		return &database.Error{Kind: database.ErrConflict, Msg: fmt.Sprintf("двухфакторная аутентификация пользователя с ID %s уже подключена", userID)}
+	}
+	s.mfa[userID] = &mfaRecord{secret: secret, createdAt: s.now().UTC()}
+
+	if err := s.logActionLocked(userID, "Начато подключение двухфакторной аутентификации"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ConfirmMFA(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mfaLocked(userID)
+	if !ok || !m.confirmedAt.IsZero() {
+		return database.NotFound("неподтвержденная двухфакторная аутентификация пользователя с ID %s не найдена", userID)
+	}
+	m.confirmedAt = s.now().UTC()
+	m.lastUsedStep = step
+	m.recoveryCodes = recoveryCodeSet(recoveryCodeHashes)
+
+	if err := s.logActionLocked(userID, "Двухфакторная аутентификация подключена"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) UseMFAStep(ctx context.Context, userID string, step int64) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mfaLocked(userID)
+	if !ok || m.confirmedAt.IsZero() || m.lastUsedStep >= step {
+		return &database.Error{Kind: database.ErrInvalidToken, Msg: "код двухфакторной аутентификации уже использован"}
+	}
+	m.lastUsedStep = step
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mfaLocked(userID)
+	if !ok {
+		return &database.Error{Kind: database.ErrInvalidToken, Msg: "код восстановления недействителен или уже использован"}
+	}
+	used, exists := m.recoveryCodes[codeHash]
+	if !exists || used {
+		return &database.Error{Kind: database.ErrInvalidToken, Msg: "код восстановления недействителен или уже использован"}
+	}
+	m.recoveryCodes[codeHash] = true
+
+	if err := s.logActionLocked(userID, "Использован код восстановления двухфакторной аутентификации"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mfaLocked(userID)
+	if !ok || m.confirmedAt.IsZero() {
+		return database.NotFound("двухфакторная аутентификация пользователя с ID %s не подключена", userID)
+	}
+	m.recoveryCodes = recoveryCodeSet(codeHashes)
+
+	if err := s.logActionLocked(userID, "Коды восстановления двухфакторной аутентификации перевыпущены"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DisableMFA(ctx context.Context, userID string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.mfa[userID]; !ok {
+		return database.NotFound("двухфакторная аутентификация пользователя с ID %s не подключена", userID)
+	}
+	delete(s.mfa, userID)
+
+	if err := s.logActionLocked(userID, "Двухфакторная аутентификация отключена"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+// mfaLocked возвращает настройки двухфакторной аутентификации неудаленного пользователя. Вызывается под s.mu
+func (s *Store) mfaLocked(userID string) (*mfaRecord, bool) {
+	if _, ok := s.activeUserLocked(userID, ""); !ok {
+		return nil, false
+	}
+	m, ok := s.mfa[userID]
+	return m, ok
+}
+
+func recoveryCodeSet(codeHashes []string) map[string]bool {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, h := range codeHashes {
+		codes[h] = false
+	}
+	return codes
+}
+
+// clone возвращает независимую копию записи
+func (m *mfaRecord) clone() *mfaRecord {
+	copied := *m
+	copied.recoveryCodes = make(map[string]bool, len(m.recoveryCodes))
+	for h, used := range m.recoveryCodes {
+		copied.recoveryCodes[h] = used
+	}
+	return &copied
+}
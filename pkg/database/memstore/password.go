@@ -0,0 +1,137 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/util"
+)
+
+type resetTokenRecord struct {
+	userID    string
+	expiresAt time.Time
+	used      bool
+}
+
+func (s *Store) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(userID, "")
+	if !ok {
+		return database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	if err := util.CheckPassword(u.passwordHash, oldPassword); err != nil {
+		if errors.Is(err, util.ErrMismatchedPassword) {
+			return &database.Error{Kind: database.ErrInvalidInput, Msg: "неверный текущий пароль", Err: err}
+		}
+		return err
+	}
+
+	if err := s.setPasswordLocked(u, newPassword); err != nil {
+		return err
+	}
+	if err := s.logActionLocked(userID, "Пароль изменен пользователем"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) CreatePasswordResetToken(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	if err := checkID(userID); err != nil {
+		return "", err
+	}
+
+	if ttl <= 0 {
+		ttl = database.DefaultResetTokenTTL
+	}
+	token, tokenHash, err := database.NewResetToken()
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activeUserLocked(userID, ""); !ok {
+		return "", database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	s.expireResetTokensLocked(userID)
+	s.resetTokens[tokenHash] = &resetTokenRecord{userID: userID, expiresAt: s.now().Add(ttl)}
+
+	if err := s.logActionLocked(userID, "Выдан токен сброса пароля"); err != nil {
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return token, nil
+}
+
+func (s *Store) ResetPassword(ctx context.Context, token, newPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.resetTokens[database.HashResetToken(token)]
+	if !ok || t.used || !s.now().Before(t.expiresAt) {
+		return &database.Error{Kind: database.ErrInvalidToken, Msg: "токен сброса пароля недействителен или истек"}
+	}
+	u, ok := s.activeUserLocked(t.userID, "")
+	if !ok {
+		return &database.Error{Kind: database.ErrInvalidToken, Msg: "токен сброса пароля недействителен или истек"}
+	}
+
+	// Токен помечается использованным только вместе с успешной сменой пароля
+	if err := s.setPasswordLocked(u, newPassword); err != nil {
+		return err
+	}
+	if err := s.logActionLocked(u.id, "Пароль сброшен по токену"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) TokensValidAfter(ctx context.Context, userID string) (time.Time, error) {
+	if err := checkID(userID); err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUserLocked(userID, "")
+	if !ok {
+		return time.Time{}, database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	return u.tokensValidAfter, nil
+}
+
+// setPasswordLocked проверяет и сохраняет новый пароль, отзывает выданные токены и токены сброса.
+// Вызывается под s.mu
+func (s *Store) setPasswordLocked(u *userRecord, newPassword string) error {
+	passwordHash, err := database.PreparePassword(u.username, newPassword)
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	u.passwordHash = passwordHash
+	u.tokensValidAfter = now
+	u.updatedAt = now
+	s.expireResetTokensLocked(u.id)
+	return nil
+}
+
+// expireResetTokensLocked помечает неиспользованные токены сброса пароля пользователя как использованные.
+// Вызывается под s.mu
+func (s *Store) expireResetTokensLocked(userID string) {
+	for _, t := range s.resetTokens {
+		if t.userID == userID {
+			t.used = true
+		}
+	}
+}
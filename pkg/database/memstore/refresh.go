@@ -0,0 +1,123 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, userID, jti, device string, expiresAt time.Time) (model.RefreshToken, error) {
+	if err := checkID(userID); err != nil {
+		return model.RefreshToken{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activeUserLocked(userID, ""); !ok {
+		return model.RefreshToken{}, database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	t := &model.RefreshToken{
+		TokenHash: database.HashTokenID(jti),
+		FamilyID:  newID(),
+		UserID:    userID,
+		Device:    device,
+		ExpiresAt: expiresAt,
+		CreatedAt: s.now().UTC(),
+	}
+	s.refreshTokens[t.TokenHash] = t
+
+	if err := s.logActionLocked(userID, fmt.Sprintf("Начат сеанс на устройстве %q", device)); err != nil {
+		return model.RefreshToken{}, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return *t, nil
+}
+
+func (s *Store) RotateRefreshToken(ctx context.Context, oldJTI, newJTI string, expiresAt time.Time) (model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invalid := &database.Error{Kind: database.ErrInvalidToken, Msg: "рефреш токен недействителен или истек"}
+	now := s.now().UTC()
+	old, ok := s.refreshTokens[database.HashTokenID(oldJTI)]
+	if !ok || old.RevokedAt != nil || !now.Before(old.ExpiresAt) {
+		return model.RefreshToken{}, invalid
+	}
+
+	if old.UsedAt != nil {
+		if err := s.revokeReusedFamilyLocked(old); err != nil {
+			return model.RefreshToken{}, err
+		}
+		return model.RefreshToken{}, &database.Error{Kind: database.ErrTokenReused, Msg: "рефреш токен использован повторно, сеанс отозван", Err: database.ErrInvalidToken}
+	}
+	if _, ok := s.activeUserLocked(old.UserID, ""); !ok {
+		return model.RefreshToken{}, invalid
+	}
+
+	old.UsedAt = timeRef(now)
+	t := &model.RefreshToken{
+		TokenHash:  database.HashTokenID(newJTI),
+		FamilyID:   old.FamilyID,
+		ParentHash: &old.TokenHash,
+		UserID:     old.UserID,
+		Device:     old.Device,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	}
+	s.refreshTokens[t.TokenHash] = t
+	return *t, nil
+}
+
+// revokeReusedFamilyLocked отзывает семейство повторно предъявленного токена и записывает это в журнал.
+// Внутри WithTx отзыв, как и в PostgreSQL, применяется только при успешном завершении транзакции. Вызывается под s.mu
+func (s *Store) revokeReusedFamilyLocked(reused *model.RefreshToken) error {
+	s.revokeRefreshFamilyLocked(reused.FamilyID)
+	if err := s.logActionLocked(reused.UserID, fmt.Sprintf("Повторное использование рефреш токена на устройстве %q, сеанс отозван", reused.Device)); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	if err := checkID(familyID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var member *model.RefreshToken
+	for _, t := range s.refreshTokens {
+		if t.FamilyID == familyID {
+			member = t
+			break
+		}
+	}
+	if member == nil {
+		return database.NotFound("сеанс %s не найден", familyID)
+	}
+	s.revokeRefreshFamilyLocked(familyID)
+
+	if err := s.logActionLocked(member.UserID, fmt.Sprintf("Сеанс на устройстве %q завершен", member.Device)); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+// revokeRefreshFamilyLocked отзывает неотозванные токены семейства. Вызывается под s.mu
+func (s *Store) revokeRefreshFamilyLocked(familyID string) {
+	now := s.now().UTC()
+	for _, t := range s.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = timeRef(now)
+		}
+	}
+}
+
+// timeRef возвращает указатель на копию t для полей, где nil означает «не задано»
+func timeRef(t time.Time) *time.Time {
+	return &t
+}
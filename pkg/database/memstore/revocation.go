@@ -0,0 +1,92 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+)
+
+type revokedTokenRecord struct {
+	userID    string
+	expiresAt time.Time
+}
+
+func (s *Store) RevokeToken(ctx context.Context, userID, jti string, expiresAt time.Time) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		// Повторяем поведение внешнего ключа revoked_tokens.user_id
+		return &database.Error{Kind: database.ErrConflict, Msg: fmt.Sprintf("ошибка отзыва токена: пользователь с ID %s не существует", userID)}
+	}
+	hash := database.HashTokenID(jti)
+	if _, ok := s.revokedTokens[hash]; !ok {
+		s.revokedTokens[hash] = &revokedTokenRecord{userID: userID, expiresAt: expiresAt}
+	}
+
+	if err := s.logActionLocked(userID, "Токен доступа отозван"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokeAllForUser(ctx context.Context, userID string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	s.revokeAllTokensLocked(u)
+
+	if err := s.logActionLocked(userID, "Отозваны все токены пользователя"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) TokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revokedTokens[database.HashTokenID(jti)]
+	return ok, nil
+}
+
+func (s *Store) PurgeExpiredRevocations(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	purged := 0
+	for hash, t := range s.revokedTokens {
+		if !t.expiresAt.After(now) {
+			delete(s.revokedTokens, hash)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// revokeAllTokensLocked сдвигает момент, до которого токены пользователя недействительны,
+// и отзывает его рефреш токены. Вызывается под s.mu
+func (s *Store) revokeAllTokensLocked(u *userRecord) {
+	now := s.now().UTC()
+	u.tokensValidAfter = now
+	for _, t := range s.refreshTokens {
+		if t.UserID == u.id && t.RevokedAt == nil {
+			t.RevokedAt = timeRef(now)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+// trigrams повторяет разбиение строки на триграммы из pg_trgm: строка приводится к нижнему регистру,
+// делится на слова из букв и цифр, каждое слово дополняется двумя пробелами слева и одним справа
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// similarity повторяет функцию similarity из pg_trgm
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// nameScore возвращает ранг совпадения запроса с текстовыми полями или false, если совпадения нет
+func nameScore(terms database.SearchTerms, fields ...string) (float64, bool) {
+	score, matched := 0.0, false
+	for _, f := range fields {
+		sim := similarity(f, terms.Text)
+		if sim >= database.SimilarityThreshold {
+			matched = true
+		}
+		if strings.Contains(strings.ToLower(f), terms.Text) {
+			matched = true
+			sim = max(sim, database.SubstringScore)
+		}
+		score = max(score, sim)
+	}
+	return score, matched
+}
+
+// searchLocked отбирает пользователей с ролью role, для которых match возвращает совпадение,
+// и упорядочивает их по убыванию ранга. Вызывается под s.mu
+func (s *Store) searchLocked(role string, opts database.SearchOptions, match func(u *userRecord) (float64, bool)) ([]*userRecord, []float64, int) {
+	type entry struct {
+		u     *userRecord
+		score float64
+	}
+	var entries []entry
+	for _, u := range s.users {
+		if (role != "" && u.role != role) || (u.isDeleted && !opts.IncludeDeleted) {
+			continue
+		}
+		if score, ok := match(u); ok {
+			entries = append(entries, entry{u: u, score: score})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].score != entries[j].score {
+			return entries[i].score > entries[j].score
+		}
+		return entries[i].u.id < entries[j].u.id
+	})
+
+	next := 0
+	if opts.Offset >= len(entries) {
+		entries = nil
+	} else {
+		entries = entries[opts.Offset:]
+	}
+	if len(entries) > opts.Limit {
+		entries = entries[:opts.Limit]
+		next = opts.Offset + opts.Limit
+	}
+
+	users := make([]*userRecord, len(entries))
+	scores := make([]float64, len(entries))
+	for i, e := range entries {
+		users[i], scores[i] = e.u, e.score
+	}
+	return users, scores, next
+}
+
+func (s *Store) SearchUsers(ctx context.Context, opts database.SearchOptions) (database.SearchResult[model.User], error) {
+	opts, terms, err := database.NormalizeSearch(opts)
+	if err != nil {
+		return database.SearchResult[model.User]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, scores, next := s.searchLocked(opts.Role, opts, func(u *userRecord) (float64, bool) {
+		return nameScore(terms, u.username)
+	})
+
+	result := database.SearchResult[model.User]{Hits: []database.SearchHit[model.User]{}, NextOffset: next}
+	for i, u := range records {
+		user := u.toModel()
+		user.PasswordHash = ""
+		result.Hits = append(result.Hits, database.SearchHit[model.User]{Item: user, Score: scores[i]})
+	}
+	return result, nil
+}
+
+func (s *Store) SearchClients(ctx context.Context, opts database.SearchOptions) (database.SearchResult[model.Client], error) {
+	opts, terms, err := database.NormalizeSearch(opts)
+	if err != nil {
+		return database.SearchResult[model.Client]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, scores, next := s.searchLocked("client", opts, func(u *userRecord) (float64, bool) {
+		c := s.clients[u.id]
+		score, ok := nameScore(terms, u.username, c.fullName)
+		if terms.Digits != "" && strings.HasSuffix(database.NormalizePhone(c.phoneNumber), terms.Digits) {
+			return database.PhoneScore, true
+		}
+		return score, ok
+	})
+
+	result := database.SearchResult[model.Client]{Hits: []database.SearchHit[model.Client]{}, NextOffset: next}
+	for i, u := range records {
+		result.Hits = append(result.Hits, database.SearchHit[model.Client]{Item: s.clientModelLocked(u), Score: scores[i]})
+	}
+	return result, nil
+}
+
+func (s *Store) SearchManagers(ctx context.Context, opts database.SearchOptions) (database.SearchResult[model.Manager], error) {
+	opts, terms, err := database.NormalizeSearch(opts)
+	if err != nil {
+		return database.SearchResult[model.Manager]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records, scores, next := s.searchLocked("manager", opts, func(u *userRecord) (float64, bool) {
+		return nameScore(terms, u.username, s.managers[u.id].fullName)
+	})
+
+	result := database.SearchResult[model.Manager]{Hits: []database.SearchHit[model.Manager]{}, NextOffset: next}
+	for i, u := range records {
+		result.Hits = append(result.Hits, database.SearchHit[model.Manager]{Item: s.managerModelLocked(u), Score: scores[i]})
+	}
+	return result, nil
+}
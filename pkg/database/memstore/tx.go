@@ -0,0 +1,72 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+// WithTx выполняет fn над копией данных и применяет изменения, только если fn вернула nil.
+// На время выполнения fn остальные операции с хранилищем ожидают, поэтому внутри fn
+// нужно обращаться только к tx, а не к исходному хранилищу
+func (s *Store) WithTx(ctx context.Context, fn func(tx database.Repos) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.cloneLocked()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	s.users, s.order, s.admins, s.clients, s.managers, s.logs = tx.users, tx.order, tx.admins, tx.clients, tx.managers, tx.logs
+	s.resetTokens, s.loginAttempts, s.mfa = tx.resetTokens, tx.loginAttempts, tx.mfa
+	s.refreshTokens, s.revokedTokens = tx.refreshTokens, tx.revokedTokens
+	return nil
+}
+
+// cloneLocked возвращает независимую копию данных хранилища. Вызывается под s.mu
+func (s *Store) cloneLocked() *Store {
+	c := New()
+	c.now = s.now
+	for id, u := range s.users {
+		copied := *u
+		c.users[id] = &copied
+	}
+	c.order = append([]string(nil), s.order...)
+	// Права администраторов не изменяются на месте, а заменяются целиком
+	for id, p := range s.admins {
+		c.admins[id] = p
+	}
+	for id, cl := range s.clients {
+		copied := *cl
+		c.clients[id] = &copied
+	}
+	for id, m := range s.managers {
+		copied := *m
+		c.managers[id] = &copied
+	}
+	c.logs = append([]model.UserLog(nil), s.logs...)
+	for hash, t := range s.resetTokens {
+		copied := *t
+		c.resetTokens[hash] = &copied
+	}
+	for key, a := range s.loginAttempts {
+		copied := *a
+		c.loginAttempts[key] = &copied
+	}
+	for id, m := range s.mfa {
+		c.mfa[id] = m.clone()
+	}
+	for hash, t := range s.refreshTokens {
+		copied := *t
+		c.refreshTokens[hash] = &copied
+	}
+	for hash, t := range s.revokedTokens {
+		copied := *t
+		c.revokedTokens[hash] = &copied
+	}
+	return c
+}
@@ -0,0 +1,182 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+// errEmptyPatch возвращается, если патч не изменяет ни одного поля
+var errEmptyPatch = &database.Error{Kind: database.ErrInvalidInput, Msg: "нет полей для обновления"}
+
+func (s *Store) UpdateUser(ctx context.Context, userID string, patch model.UserPatch) (model.User, error) {
+	if err := checkID(userID); err != nil {
+		return model.User{}, err
+	}
+
+	fields := patch.Fields()
+	if len(fields) == 0 {
+		return model.User{}, errEmptyPatch
+	}
+	if err := patch.Validate(); err != nil {
+		return model.User{}, database.Invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(userID, "")
+	if !ok {
+		return model.User{}, database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	if patch.Username != nil && *patch.Username != u.username {
+		for _, other := range s.users {
+			if other.username == *patch.Username {
+				return model.User{}, &database.Error{Kind: database.ErrUsernameTaken, Msg: fmt.Sprintf("пользователь с именем %s уже существует", *patch.Username)}
+			}
+		}
+		// Счетчик неудачных попыток входа переходит к новому имени, см. database.Store.UpdateUser
+		oldKey, newKey := database.UserLockoutKey(u.username), database.UserLockoutKey(*patch.Username)
+		delete(s.loginAttempts, newKey)
+		if a, ok := s.loginAttempts[oldKey]; ok {
+			s.loginAttempts[newKey] = a
+			delete(s.loginAttempts, oldKey)
+		}
+		u.username = *patch.Username
+	}
+	u.updatedAt = s.now().UTC()
+
+	if err := s.logActionLocked(userID, fmt.Sprintf("Пользователь обновлен: %s", strings.Join(fields, ", "))); err != nil {
+		return u.toModel(), fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return u.toModel(), nil
+}
+
+func (s *Store) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	if err := checkID(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(userID, "")
+	if !ok {
+		return database.NotFound("пользователь с ID %s не найден", userID)
+	}
+	u.passwordHash = passwordHash
+
+	if err := s.logActionLocked(userID, "Хеш пароля пересчитан"); err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) UpdateAdminPermissions(ctx context.Context, adminID string, permissions map[string]interface{}) (model.Admin, error) {
+	if err := checkID(adminID); err != nil {
+		return model.Admin{}, err
+	}
+	if err := model.ValidatePermissions(permissions); err != nil {
+		return model.Admin{}, database.Invalid(err)
+	}
+
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return model.Admin{}, &database.Error{Kind: database.ErrInvalidInput, Msg: "ошибка преобразования permissions в JSON", Err: err}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(adminID, "admin")
+	if !ok {
+		return model.Admin{}, database.NotFound("администратор с ID %s не найден", adminID)
+	}
+	s.admins[u.id] = permissionsJSON
+	u.updatedAt = s.now().UTC()
+
+	admin, err := s.adminModelLocked(u)
+	if err != nil {
+		return admin, err
+	}
+	if err := s.logActionLocked(adminID, "Права администратора обновлены: permissions"); err != nil {
+		return admin, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return admin, nil
+}
+
+func (s *Store) UpdateClient(ctx context.Context, clientID string, patch model.ClientPatch) (model.Client, error) {
+	if err := checkID(clientID); err != nil {
+		return model.Client{}, err
+	}
+
+	fields := patch.Fields()
+	if len(fields) == 0 {
+		return model.Client{}, errEmptyPatch
+	}
+	if err := patch.Validate(); err != nil {
+		return model.Client{}, database.Invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(clientID, "client")
+	if !ok {
+		return model.Client{}, database.NotFound("клиент с ID %s не найден", clientID)
+	}
+	c := s.clients[u.id]
+	if patch.FullName != nil {
+		c.fullName = *patch.FullName
+	}
+	if patch.PhoneNumber != nil {
+		c.phoneNumber = *patch.PhoneNumber
+	}
+	u.updatedAt = s.now().UTC()
+
+	client := s.clientModelLocked(u)
+	if err := s.logActionLocked(clientID, fmt.Sprintf("Клиент обновлен: %s", strings.Join(fields, ", "))); err != nil {
+		return client, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return client, nil
+}
+
+func (s *Store) UpdateManager(ctx context.Context, managerID string, patch model.ManagerPatch) (model.Manager, error) {
+	if err := checkID(managerID); err != nil {
+		return model.Manager{}, err
+	}
+
+	fields := patch.Fields()
+	if len(fields) == 0 {
+		return model.Manager{}, errEmptyPatch
+	}
+	if err := patch.Validate(); err != nil {
+		return model.Manager{}, database.Invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUserLocked(managerID, "manager")
+	if !ok {
+		return model.Manager{}, database.NotFound("менеджер с ID %s не найден", managerID)
+	}
+	m := s.managers[u.id]
+	if patch.FullName != nil {
+		m.fullName = *patch.FullName
+	}
+	if patch.HireDate != nil {
+		m.hireDate = *patch.HireDate
+	}
+	u.updatedAt = s.now().UTC()
+
+	manager := s.managerModelLocked(u)
+	if err := s.logActionLocked(managerID, fmt.Sprintf("Менеджер обновлен: %s", strings.Join(fields, ", "))); err != nil {
+		return manager, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return manager, nil
+}
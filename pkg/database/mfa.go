@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// errMFAStepUsed возвращается UseMFAStep для кода, шаг которого уже принимался
+var errMFAStepUsed = &Error{Kind: ErrInvalidToken, Msg: "код двухфакторной аутентификации уже использован"}
+
+// errRecoveryCode возвращается UseRecoveryCode для неизвестного или использованного кода восстановления
+var errRecoveryCode = &Error{Kind: ErrInvalidToken, Msg: "код восстановления недействителен или уже использован"}
+
+// GetMFA возвращает настройки двухфакторной аутентификации пользователя.
+// Если подключение не начиналось, возвращается ошибка категории ErrNotFound
+func (db *Store) GetMFA(ctx context.Context, userID string) (model.MFA, error) {
+	var mfa model.MFA
+	query := `SELECT m.user_id, m.secret, m.confirmed_at, m.last_used_step, m.created_at
+			  FROM user_mfa m JOIN users u ON u.id = m.user_id
+			  WHERE m.user_id = $1 AND u.is_deleted = false`
+	err := db.conn().QueryRow(ctx, query, userID).Scan(&mfa.UserID, &mfa.Secret, &mfa.ConfirmedAt, &mfa.LastUsedStep, &mfa.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return mfa, NotFound("двухфакторная аутентификация пользователя с ID %s не подключена", userID)
+		}
+		return mfa, wrapError("ошибка получения настроек двухфакторной аутентификации", err)
+	}
+	return mfa, nil
+}
+
+// SaveMFASecret начинает подключение двухфакторной аутентификации с секретом secret.
+// Неподтвержденный секрет заменяется, подтвержденный — нет: сначала нужно вызвать DisableMFA
+func (db *Store) SaveMFASecret(ctx context.Context, userID, secret string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	var exists bool
+	err = tx.conn().QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = false)`, userID).Scan(&exists)
+	if err != nil {
+		return wrapError("ошибка получения пользователя", err)
+	}
+	if !exists {
+		return NotFound("пользователь с ID %s не найден", userID)
+	}
+
+	query := `INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+			  ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+			  WHERE user_mfa.confirmed_at IS NULL`
+	tag, err := tx.conn().Exec(ctx, query, userID, secret)
+	if err != nil {
+		return wrapError("ошибка сохранения секрета двухфакторной аутентификации", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return &Error{Kind: ErrConflict, Msg: fmt.Sprintf("двухфакторная аутентификация пользователя с ID %s уже подключена", userID)}
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Начато подключение двухфакторной аутентификации")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// ConfirmMFA подтверждает подключение двухфакторной аутентификации: step — шаг TOTP проверенного кода,
+// recoveryCodeHashes — хеши выданных кодов восстановления
+func (db *Store) ConfirmMFA(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	query := `UPDATE user_mfa m SET confirmed_at = now(), last_used_step = $2
+			  FROM users u
+			  WHERE m.user_id = $1 AND m.confirmed_at IS NULL AND u.id = m.user_id AND u.is_deleted = false`
+	tag, err := tx.conn().Exec(ctx, query, userID, step)
+	if err != nil {
+		return wrapError("ошибка подтверждения двухфакторной аутентификации", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return NotFound("неподтвержденная двухфакторная аутентификация пользователя с ID %s не найдена", userID)
+	}
+
+	if err := tx.insertRecoveryCodes(ctx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Двухфакторная аутентификация подключена")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// UseMFAStep принимает код с шагом TOTP step. Шаг должен быть больше последнего принятого,
+// иначе возвращается ошибка категории ErrInvalidToken: так один код нельзя использовать дважды
+func (db *Store) UseMFAStep(ctx context.Context, userID string, step int64) error {
+	query := `UPDATE user_mfa SET last_used_step = $2
+			  WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`
+	tag, err := db.conn().Exec(ctx, query, userID, step)
+	if err != nil {
+		return wrapError("ошибка проверки кода двухфакторной аутентификации", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errMFAStepUsed
+	}
+	return nil
+}
+
+// UseRecoveryCode помечает код восстановления с хешем codeHash использованным.
+// Для неизвестного или уже использованного кода возвращается ошибка категории ErrInvalidToken
+func (db *Store) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	query := `UPDATE mfa_recovery_codes SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	tag, err := tx.conn().Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return wrapError("ошибка проверки кода восстановления", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errRecoveryCode
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Использован код восстановления двухфакторной аутентификации")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// ReplaceRecoveryCodes заменяет все коды восстановления пользователя новыми
+func (db *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	var confirmed bool
+	query := `SELECT m.confirmed_at IS NOT NULL FROM user_mfa m JOIN users u ON u.id = m.user_id
+			  WHERE m.user_id = $1 AND u.is_deleted = false FOR UPDATE OF m`
+	err = tx.conn().QueryRow(ctx, query, userID).Scan(&confirmed)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return wrapError("ошибка получения настроек двухфакторной аутентификации", err)
+	}
+	if !confirmed {
+		return NotFound("двухфакторная аутентификация пользователя с ID %s не подключена", userID)
+	}
+
+	if _, err := tx.conn().Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return wrapError("ошибка удаления кодов восстановления", err)
+	}
+	if err := tx.insertRecoveryCodes(ctx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Коды восстановления двухфакторной аутентификации перевыпущены")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// DisableMFA отключает двухфакторную аутентификацию и удаляет коды восстановления
+func (db *Store) DisableMFA(ctx context.Context, userID string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	tag, err := tx.conn().Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	if err != nil {
+		return wrapError("ошибка отключения двухфакторной аутентификации", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return NotFound("двухфакторная аутентификация пользователя с ID %s не подключена", userID)
+	}
+	if _, err := tx.conn().Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return wrapError("ошибка удаления кодов восстановления", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Двухфакторная аутентификация отключена")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// insertRecoveryCodes сохраняет хеши кодов восстановления. Вызывается внутри транзакции
+func (db *Store) insertRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	query := `INSERT INTO mfa_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`
+	if _, err := db.conn().Exec(ctx, query, userID, codeHashes); err != nil {
+		return wrapError("ошибка сохранения кодов восстановления", err)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Миграции схемы лежат в migrations/ в виде пар NNNN_name.up.sql и NNNN_name.down.sql
+// и встраиваются в библиотеку, поэтому версия схемы всегда соответствует версии crmlib
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID — ключ рекомендательной блокировки, которая не дает
+// нескольким экземплярам сервиса применять миграции одновременно
+const migrationLockID int64 = 0x63726d6c6962 // "crmlib"
+
+// Migration — одна версия схемы
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState — версия схемы и признак того, что она применена к базе
+type MigrationState struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations возвращает встроенные миграции в порядке возрастания версии
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("некорректное имя файла миграции %s", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная версия в имени файла миграции %s: %w", name, err)
+		}
+
+		sql, err := migrationsFS.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("у миграции %04d_%s нет файла up или down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// SchemaVersion возвращает последнюю версию схемы, которую ожидает библиотека
+func SchemaVersion() int {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// baselineVersion — миграция с базовыми таблицами и функциями, которые в существующих базах
+// были созданы до появления встроенных миграций
+const baselineVersion = 1
+
+// Migrate применяет все непримененные миграции. Каждая миграция выполняется в отдельной транзакции.
+// Если в базе еще нет примененных миграций, но таблица users уже существует (схема создана вне crmlib),
+// миграция 0001_init отмечается примененной без выполнения, см. Baseline
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error {
+		if len(applied) == 0 {
+			var hasUsers bool
+			if err := conn.QueryRow(ctx, `SELECT to_regclass('users') IS NOT NULL`).Scan(&hasUsers); err != nil {
+				return fmt.Errorf("ошибка проверки существующей схемы: %w", err)
+			}
+			if hasUsers {
+				if err := markApplied(ctx, conn, migrations, baselineVersion, applied); err != nil {
+					return err
+				}
+			}
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка применения миграции %04d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown откатывает примененные миграции с версией больше target в порядке убывания версии.
+// MigrateDown(ctx, pool, 0) удаляет всю схему
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, target int) error {
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok || m.Version <= target {
+				continue
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка отката миграции %04d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Baseline отмечает миграции с версией не больше version примененными, не выполняя их.
+// Нужна для базы, схема которой уже соответствует этим версиям, но создана вне crmlib
+func Baseline(ctx context.Context, pool *pgxpool.Pool, version int) error {
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error {
+		return markApplied(ctx, conn, migrations, version, applied)
+	})
+}
+
+// markApplied записывает в schema_migrations непримененные миграции с версией не больше version
+func markApplied(ctx context.Context, conn *pgxpool.Conn, migrations []Migration, version int, applied map[int]time.Time) error {
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok || m.Version > version {
+			continue
+		}
+		var appliedAt time.Time
+		err := conn.QueryRow(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2) RETURNING applied_at`,
+			m.Version, m.Name).Scan(&appliedAt)
+		if err != nil {
+			return fmt.Errorf("ошибка отметки миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		applied[m.Version] = appliedAt
+	}
+	return nil
+}
+
+// MigrationStatus возвращает все встроенные миграции с признаком применения к базе.
+// Только читает schema_migrations: не захватывает блокировку миграций и не создает таблицу
+func MigrationStatus(ctx context.Context, pool *pgxpool.Pool) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	applied := map[int]time.Time{}
+	var hasVersionTable bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&hasVersionTable); err != nil {
+		return nil, fmt.Errorf("ошибка проверки таблицы schema_migrations: %w", err)
+	}
+	if hasVersionTable {
+		if applied, err = appliedMigrations(ctx, pool); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		states = append(states, MigrationState{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+	return states, nil
+}
+
+// withMigrationLock захватывает рекомендательную блокировку на отдельном соединении,
+// создает таблицу schema_migrations и передает fn список миграций и уже примененные версии
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn, migrations []Migration, applied map[int]time.Time) error) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка получения соединения: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("ошибка захвата блокировки миграций: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		name       text        NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, migrations, applied)
+}
+
+// appliedMigrations читает из schema_migrations примененные версии и время их применения
+func appliedMigrations(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
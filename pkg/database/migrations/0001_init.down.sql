@@ -0,0 +1,12 @@
+DROP FUNCTION IF EXISTS delete_manager(uuid);
+DROP FUNCTION IF EXISTS delete_client(uuid);
+DROP FUNCTION IF EXISTS delete_admin(uuid);
+DROP FUNCTION IF EXISTS create_manager(text, text, text, date);
+DROP FUNCTION IF EXISTS create_client(text, text, text, text);
+DROP FUNCTION IF EXISTS create_admin(text, text, jsonb);
+
+DROP TABLE IF EXISTS user_logs;
+DROP TABLE IF EXISTS managers;
+DROP TABLE IF EXISTS clients;
+DROP TABLE IF EXISTS admins;
+DROP TABLE IF EXISTS users;
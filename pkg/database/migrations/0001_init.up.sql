@@ -0,0 +1,124 @@
+-- Базовые таблицы CRM и хранимые функции создания и удаления пользователей
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE users (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    username      text        NOT NULL,
+    password_hash text        NOT NULL,
+    role          text        NOT NULL CHECK (role IN ('admin', 'client', 'manager')),
+    is_deleted    boolean     NOT NULL DEFAULT false,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT users_username_key UNIQUE (username)
+);
+
+CREATE TABLE admins (
+    id          uuid PRIMARY KEY REFERENCES users (id),
+    permissions jsonb NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE clients (
+    id           uuid PRIMARY KEY REFERENCES users (id),
+    full_name    text NOT NULL,
+    phone_number text NOT NULL
+);
+
+CREATE TABLE managers (
+    id        uuid PRIMARY KEY REFERENCES users (id),
+    full_name text NOT NULL,
+    hire_date date NOT NULL
+);
+
+-- user_id становится NULL при анонимизации журнала физически удаленного пользователя
+CREATE TABLE user_logs (
+    id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id   uuid REFERENCES users (id),
+    action    text        NOT NULL,
+    timestamp timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_logs_user_id_idx ON user_logs (user_id, timestamp);
+
+-- Индексы для постраничной выборки ListUsers/ListClients/ListManagers/ListAdmins
+CREATE INDEX users_created_at_idx ON users (created_at, id);
+CREATE INDEX users_username_c_idx ON users ((username COLLATE "C"), id);
+CREATE INDEX users_role_created_at_idx ON users (role, created_at, id);
+CREATE INDEX users_deleted_idx ON users (updated_at) WHERE is_deleted;
+
+CREATE FUNCTION create_admin(p_username text, p_password_hash text, p_permissions jsonb)
+RETURNS uuid LANGUAGE plpgsql AS $$
+DECLARE
+    v_id uuid;
+BEGIN
+    INSERT INTO users (username, password_hash, role)
+    VALUES (p_username, p_password_hash, 'admin')
+    RETURNING id INTO v_id;
+
+    INSERT INTO admins (id, permissions) VALUES (v_id, COALESCE(p_permissions, '{}'));
+    RETURN v_id;
+END;
+$$;
+
+CREATE FUNCTION create_client(p_username text, p_password_hash text, p_full_name text, p_phone_number text)
+RETURNS uuid LANGUAGE plpgsql AS $$
+DECLARE
+    v_id uuid;
+BEGIN
+    INSERT INTO users (username, password_hash, role)
+    VALUES (p_username, p_password_hash, 'client')
+    RETURNING id INTO v_id;
+
+    INSERT INTO clients (id, full_name, phone_number) VALUES (v_id, p_full_name, p_phone_number);
+    RETURN v_id;
+END;
+$$;
+
+CREATE FUNCTION create_manager(p_username text, p_password_hash text, p_full_name text, p_hire_date date)
+RETURNS uuid LANGUAGE plpgsql AS $$
+DECLARE
+    v_id uuid;
+BEGIN
+    INSERT INTO users (username, password_hash, role)
+    VALUES (p_username, p_password_hash, 'manager')
+    RETURNING id INTO v_id;
+
+    INSERT INTO managers (id, full_name, hire_date) VALUES (v_id, p_full_name, p_hire_date);
+    RETURN v_id;
+END;
+$$;
+
+-- Логическое удаление. Время удаления сохраняется в updated_at.
+-- Код no_data_found отображается в database.ErrNotFound
+CREATE FUNCTION delete_admin(p_id uuid)
+RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE users SET is_deleted = true, updated_at = now()
+    WHERE id = p_id AND role = 'admin' AND is_deleted = false;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'администратор с ID % не найден', p_id USING ERRCODE = 'no_data_found';
+    END IF;
+END;
+$$;
+
+CREATE FUNCTION delete_client(p_id uuid)
+RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE users SET is_deleted = true, updated_at = now()
+    WHERE id = p_id AND role = 'client' AND is_deleted = false;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'клиент с ID % не найден', p_id USING ERRCODE = 'no_data_found';
+    END IF;
+END;
+$$;
+
+CREATE FUNCTION delete_manager(p_id uuid)
+RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE users SET is_deleted = true, updated_at = now()
+    WHERE id = p_id AND role = 'manager' AND is_deleted = false;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'менеджер с ID % не найден', p_id USING ERRCODE = 'no_data_found';
+    END IF;
+END;
+$$;
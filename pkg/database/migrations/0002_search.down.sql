@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS managers_full_name_trgm_idx;
+DROP INDEX IF EXISTS clients_phone_digits_trgm_idx;
+DROP INDEX IF EXISTS clients_full_name_trgm_idx;
+DROP INDEX IF EXISTS users_username_trgm_idx;
@@ -0,0 +1,8 @@
+-- Триграммные индексы для SearchUsers/SearchClients/SearchManagers
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_username_trgm_idx ON users USING gin (username gin_trgm_ops);
+CREATE INDEX clients_full_name_trgm_idx ON clients USING gin (full_name gin_trgm_ops);
+CREATE INDEX clients_phone_digits_trgm_idx ON clients USING gin ((regexp_replace(phone_number, '\D', '', 'g')) gin_trgm_ops);
+CREATE INDEX managers_full_name_trgm_idx ON managers USING gin (full_name gin_trgm_ops);
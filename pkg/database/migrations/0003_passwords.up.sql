@@ -0,0 +1,16 @@
+-- Смена и сброс пароля
+
+-- Токены, выданные до этого момента, недействительны. NULL — ограничений нет
+ALTER TABLE users ADD COLUMN tokens_valid_after timestamptz;
+
+-- Одноразовые токены сброса пароля. Хранится только SHA-256 токена
+CREATE TABLE password_reset_tokens (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash text        NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id) WHERE used_at IS NULL;
@@ -0,0 +1,10 @@
+-- Защита от подбора пароля
+
+-- Неудачные попытки входа по ключу "user:<имя>" или "ip:<адрес>".
+-- Хранятся в базе, чтобы блокировка действовала на всех экземплярах сервиса
+CREATE TABLE login_attempts (
+    key             text PRIMARY KEY,
+    failures        integer     NOT NULL DEFAULT 0,
+    last_failure_at timestamptz NOT NULL DEFAULT now(),
+    locked_until    timestamptz
+);
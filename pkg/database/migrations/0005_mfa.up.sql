@@ -0,0 +1,20 @@
+-- Двухфакторная аутентификация (TOTP, RFC 6238)
+
+-- Секрет TOTP пользователя. confirmed_at IS NULL — подключение начато, но не подтверждено кодом
+CREATE TABLE user_mfa (
+    user_id        uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         text        NOT NULL,
+    confirmed_at   timestamptz,
+    last_used_step bigint      NOT NULL DEFAULT 0,
+    created_at     timestamptz NOT NULL DEFAULT now()
+);
+
+-- Одноразовые коды восстановления. Хранится только SHA-256 кода
+CREATE TABLE mfa_recovery_codes (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  text NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,18 @@
+-- Семейства рефреш токенов с ротацией
+
+-- Каждый выданный рефреш токен. Хранится только SHA-256 jti.
+-- Повторное предъявление использованного токена отзывает все семейство
+CREATE TABLE refresh_tokens (
+    token_hash  text PRIMARY KEY,
+    family_id   uuid        NOT NULL,
+    parent_hash text,
+    user_id     uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device      text        NOT NULL DEFAULT '',
+    expires_at  timestamptz NOT NULL,
+    used_at     timestamptz,
+    revoked_at  timestamptz,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
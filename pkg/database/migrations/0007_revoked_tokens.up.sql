@@ -0,0 +1,11 @@
+-- Отзыв токенов доступа до истечения срока
+
+-- Отозванные токены по хешу jti. Строки нужны только до expires_at, после этого токен недействителен и так
+CREATE TABLE revoked_tokens (
+    token_hash text PRIMARY KEY,
+    user_id    uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/util"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultResetTokenTTL — время жизни токена сброса пароля, если ttl не задан
+const DefaultResetTokenTTL = time.Hour
+
+// errWrongPassword возвращается ChangePassword, если текущий пароль неверен
+var errWrongPassword = &Error{Kind: ErrInvalidInput, Msg: "неверный текущий пароль", Err: util.ErrMismatchedPassword}
+
+// errResetToken возвращается ResetPassword для неизвестного, использованного или истекшего токена
+var errResetToken = &Error{Kind: ErrInvalidToken, Msg: "токен сброса пароля недействителен или истек"}
+
+// PreparePassword проверяет новый пароль пользователя username по парольной политике
+// (util.SetPasswordPolicy) и возвращает его хеш. Нарушения политики возвращаются
+// как ошибка категории ErrInvalidInput, список нарушений доступен через errors.As(*util.PasswordPolicyError)
+func PreparePassword(username, password string) (string, error) {
+	if err := util.CheckPasswordPolicy(password, username); err != nil {
+		var policyErr *util.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return "", Invalid(err)
+		}
+		return "", err
+	}
+
+	passwordHash, err := util.HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("ошибка хеширования пароля: %w", err)
+	}
+	return passwordHash, nil
+}
+
+// NewResetToken генерирует токен сброса пароля и его хеш для хранения в базе
+func NewResetToken() (token, tokenHash string, err error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b[:])
+	return token, HashResetToken(token), nil
+}
+
+// HashResetToken возвращает SHA-256 токена сброса пароля в шестнадцатеричном виде.
+// Токен случайный и длинный, поэтому медленный хеш не нужен
+func HashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChangePassword меняет пароль пользователя после проверки текущего.
+// Выданные ранее токены пользователя и неиспользованные токены сброса пароля становятся недействительными
+func (db *Store) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	var username, passwordHash string
+	query := `SELECT username, password_hash FROM users WHERE id = $1 AND is_deleted = false FOR UPDATE`
+	err = tx.conn().QueryRow(ctx, query, userID).Scan(&username, &passwordHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return NotFound("пользователь с ID %s не найден", userID)
+		}
+		return wrapError("ошибка получения пользователя", err)
+	}
+
+	if err := util.CheckPassword(passwordHash, oldPassword); err != nil {
+		if errors.Is(err, util.ErrMismatchedPassword) {
+			return errWrongPassword
+		}
+		return err
+	}
+
+	if err := tx.setPassword(ctx, userID, username, newPassword); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Пароль изменен пользователем")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// CreatePasswordResetToken выдает одноразовый токен сброса пароля, действующий ttl (DefaultResetTokenTTL, если ttl <= 0).
+// Ранее выданные неиспользованные токены пользователя становятся недействительными.
+// В базе хранится только хеш токена, поэтому сам токен нужно сразу передать пользователю
+func (db *Store) CreatePasswordResetToken(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = DefaultResetTokenTTL
+	}
+	token, tokenHash, err := NewResetToken()
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.rollback(ctx)
+
+	var exists bool
+	err = tx.conn().QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = false)`, userID).Scan(&exists)
+	if err != nil {
+		return "", wrapError("ошибка получения пользователя", err)
+	}
+	if !exists {
+		return "", NotFound("пользователь с ID %s не найден", userID)
+	}
+
+	if err := tx.expireResetTokens(ctx, userID); err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, now() + $3::interval)`
+	if _, err := tx.conn().Exec(ctx, query, userID, tokenHash, ttl); err != nil {
+		return "", wrapError("ошибка сохранения токена сброса пароля", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Выдан токен сброса пароля")
+	if err != nil {
+		return "", fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword задает новый пароль по токену сброса. Токен используется один раз.
+// Если новый пароль нарушает политику, токен остается действительным
+func (db *Store) ResetPassword(ctx context.Context, token, newPassword string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	var userID, username string
+	query := `UPDATE password_reset_tokens t SET used_at = now()
+			  FROM users u
+			  WHERE t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > now()
+			    AND u.id = t.user_id AND u.is_deleted = false
+			  RETURNING u.id, u.username`
+	err = tx.conn().QueryRow(ctx, query, HashResetToken(token)).Scan(&userID, &username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errResetToken
+		}
+		return wrapError("ошибка проверки токена сброса пароля", err)
+	}
+
+	if err := tx.setPassword(ctx, userID, username, newPassword); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Пароль сброшен по токену")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// TokensValidAfter возвращает момент, до которого выданные пользователю токены недействительны.
+// Нулевое время — ограничений нет
+func (db *Store) TokensValidAfter(ctx context.Context, userID string) (time.Time, error) {
+	var validAfter *time.Time
+	err := db.conn().QueryRow(ctx, `SELECT tokens_valid_after FROM users WHERE id = $1 AND is_deleted = false`, userID).Scan(&validAfter)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, NotFound("пользователь с ID %s не найден", userID)
+		}
+		return time.Time{}, wrapError("ошибка получения пользователя", err)
+	}
+	if validAfter == nil {
+		return time.Time{}, nil
+	}
+	return *validAfter, nil
+}
+
+// setPassword проверяет и сохраняет новый пароль, отзывает выданные токены и токены сброса.
+// Вызывается внутри транзакции
+func (db *Store) setPassword(ctx context.Context, userID, username, newPassword string) error {
+	passwordHash, err := PreparePassword(username, newPassword)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET password_hash = $2, tokens_valid_after = now(), updated_at = now() WHERE id = $1`
+	if _, err := db.conn().Exec(ctx, query, userID, passwordHash); err != nil {
+		return wrapError("ошибка обновления пароля", err)
+	}
+	return db.expireResetTokens(ctx, userID)
+}
+
+// expireResetTokens помечает неиспользованные токены сброса пароля пользователя как использованные
+func (db *Store) expireResetTokens(ctx context.Context, userID string) error {
+	query := `UPDATE password_reset_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL`
+	if _, err := db.conn().Exec(ctx, query, userID); err != nil {
+		return wrapError("ошибка отзыва токенов сброса пароля", err)
+	}
+	return nil
+}
@@ -4,66 +4,196 @@ import (
 	"context"
 	"fmt"
 	"log"
-	// "strconv"
+	"net"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/Maden-in-haven/crmlib/pkg/config"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type db struct {
+// Store хранит пул соединений с базой данных и реализует все операции с сущностями CRM
+type Store struct {
 	Pool *pgxpool.Pool
+	tx   pgx.Tx // Транзакция, если хранилище получено в WithTx
 }
 
-var DB *db
+// DB — глобальное хранилище по умолчанию. Заполняется только после успешного вызова Default
+var DB *Store
 
-func init() {
-	// Загружаем конфигурацию базы данных
-	dbConfig := config.LoadDBConfig()
+// defaultMu защищает DB при создании в Default
+var defaultMu sync.Mutex
 
-	// Создаем строку подключения
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
-		dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName)
+// Option настраивает параметры пула соединений, создаваемого New
+type Option func(*options)
+
+type options struct {
+	maxConns          int32
+	maxConnLifetime   time.Duration
+	healthCheckPeriod time.Duration
+	skipSchemaCheck   bool
+	migrate           bool
+}
+
+// WithMaxConns задает максимальное количество соединений в пуле
+func WithMaxConns(n int32) Option {
+	return func(o *options) {
+		o.maxConns = n
+	}
+}
+
+// WithMaxConnLifetime задает максимальное время жизни соединения
+func WithMaxConnLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.maxConnLifetime = d
+	}
+}
+
+// WithHealthCheckPeriod задает период проверки активности соединений
+func WithHealthCheckPeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.healthCheckPeriod = d
+	}
+}
+
+// WithoutSchemaCheck отключает проверку совместимости схемы в New.
+// Нужна, например, чтобы подключиться к пустой базе и применить Migrate
+func WithoutSchemaCheck() Option {
+	return func(o *options) {
+		o.skipSchemaCheck = true
+	}
+}
+
+// WithMigrate применяет непримененные миграции (см. Migrate) в New перед проверкой схемы.
+// Так существующая база обновляется до версии, которую ожидает библиотека, при подключении
+func WithMigrate() Option {
+	return func(o *options) {
+		o.migrate = true
+	}
+}
+
+// New создает пул соединений по конфигурации cfg, проверяет подключение к базе данных
+// и совместимость схемы (см. CheckSchema). Несовместимая схема возвращает *SchemaError:
+// обновите базу через Migrate или подключайтесь с WithMigrate
+func New(ctx context.Context, cfg *config.DBConfig, opts ...Option) (*Store, error) {
+	o := options{
+		maxConns:          10,               // Максимум 10 соединений в пуле
+		maxConnLifetime:   30 * time.Minute, // Максимальное время жизни соединения
+		healthCheckPeriod: 1 * time.Minute,  // Период проверки активности соединений
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	// Настраиваем конфигурацию пула соединений
-	config, err := pgxpool.ParseConfig(dsn)
+	poolConfig, err := pgxpool.ParseConfig(connString(cfg))
 	if err != nil {
-		log.Fatalf("Ошибка парсинга конфигурации: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга конфигурации: %w", err)
 	}
 
 	// Настраиваем параметры пула
-	config.MaxConns = 10                 // Максимум 10 соединений в пуле
-	config.MaxConnLifetime = 30 * time.Minute // Максимальное время жизни соединения
-	config.HealthCheckPeriod = 1 * time.Minute // Период проверки активности соединений
-
-	// Создаем пул с контекстом и тайм-аутом
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
+	poolConfig.MaxConns = o.maxConns
+	poolConfig.MaxConnLifetime = o.maxConnLifetime
+	poolConfig.HealthCheckPeriod = o.healthCheckPeriod
 
-	pool, err := pgxpool.NewWithConfig(ctx, config)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+		return nil, fmt.Errorf("ошибка подключения к базе данных: %w", err)
 	}
 
 	// Проверяем соединение с помощью Ping
 	if err := pool.Ping(ctx); err != nil {
-		log.Fatalf("Ошибка проверки подключения к базе данных (ping): %v", err)
+		pool.Close()
+		return nil, fmt.Errorf("ошибка проверки подключения к базе данных (ping): %w", err)
+	}
+
+	if o.migrate {
+		if err := Migrate(ctx, pool); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	if !o.skipSchemaCheck {
+		if err := CheckSchema(ctx, pool); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	return &Store{Pool: pool}, nil
+}
+
+// connString возвращает строку подключения для cfg. Имя пользователя, пароль и имя базы
+// экранируются, поэтому могут содержать символы @, :, / и другие
+func connString(cfg *config.DBConfig) string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:   "/" + cfg.DBName,
+	}
+	return dsn.String()
+}
+
+// Default лениво создает глобальное хранилище DB по переменным окружения.
+// Подключение выполняется при первом успешном вызове, последующие вызовы возвращают DB.
+// После ошибки DB не заполняется, и следующий вызов подключается заново.
+// В строгом режиме (config.Strict) конфигурация загружается через config.LoadDBConfigStrict.
+// При POSTGRESQL_AUTO_MIGRATE=true схема обновляется при подключении, см. WithMigrate
+func Default(ctx context.Context) (*Store, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if DB != nil {
+		return DB, nil
+	}
+
+	var cfg *config.DBConfig
+	if config.Strict() {
+		var err error
+		if cfg, err = config.LoadDBConfigStrict(); err != nil {
+			return nil, err
+		}
+	} else {
+		cfg = config.LoadDBConfig()
 	}
 
-	// Сохраняем пул соединений в глобальной структуре
-	DB = &db{Pool: pool}
+	// Создаем пул с контекстом и тайм-аутом
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
 
+	var opts []Option
+	if cfg.AutoMigrate {
+		opts = append(opts, WithMigrate())
+	}
+	store, err := New(ctx, cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	DB = store
 	log.Println("Успешное подключение к базе данных с использованием пула соединений")
+	return DB, nil
+}
+
+// Close закрывает все соединения пула. Для хранилища внутри WithTx ничего не делает
+func (db *Store) Close() {
+	if db.tx != nil {
+		return
+	}
+	db.Pool.Close()
 }
 
-func (db *db) LogAction(ctx context.Context, userID, action string) error {
+// LogAction записывает действие пользователя в журнал. Пустой userID — запись без привязки к пользователю
+func (db *Store) LogAction(ctx context.Context, userID, action string) error {
 	// SQL-запрос для вставки записи в таблицу логов
-	logQuery := `INSERT INTO user_logs (user_id, action) VALUES ($1, $2)`
+	logQuery := `INSERT INTO user_logs (user_id, action) VALUES (NULLIF($1, '')::uuid, $2)`
 
 	// Выполнение SQL-запроса
-	_, err := db.Pool.Exec(ctx, logQuery, userID, action)
+	_, err := db.conn().Exec(ctx, logQuery, userID, action)
 	if err != nil {
-		return fmt.Errorf("ошибка записи лога для пользователя с ID %s: %v", userID, err)
+		return wrapError(fmt.Sprintf("ошибка записи лога для пользователя с ID %s", userID), err)
 	}
 
 	return nil
@@ -143,4 +273,4 @@ func (db *db) LogAction(ctx context.Context, userID, action string) error {
 
 //     CreateSession: Создание сессии для пользователя (например, при аутентификации).
 //     GetSession: Получение активной сессии для пользователя.
-//     DeleteSession: Завершение сессии (выход пользователя из системы).
\ No newline at end of file
+//     DeleteSession: Завершение сессии (выход пользователя из системы).
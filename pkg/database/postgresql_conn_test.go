@@ -0,0 +1,30 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/Maden-in-haven/crmlib/pkg/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestConnStringEscapesCredentials(t *testing.T) {
+	cfg := &config.DBConfig{
+		Host:     "db.example.com",
+		Port:     "5433",
+		User:     "crm@app",
+		Password: "p@ss:w/rd?#%",
+		DBName:   "crm",
+	}
+	poolConfig, err := pgxpool.ParseConfig(connString(cfg))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	conn := poolConfig.ConnConfig
+	if conn.User != cfg.User || conn.Password != cfg.Password || conn.Database != cfg.DBName {
+		t.Errorf("user=%q password=%q database=%q, ожидалось %q, %q, %q",
+			conn.User, conn.Password, conn.Database, cfg.User, cfg.Password, cfg.DBName)
+	}
+	if conn.Host != cfg.Host || conn.Port != 5433 {
+		t.Errorf("host=%q port=%d, ожидалось %q и 5433", conn.Host, conn.Port, cfg.Host)
+	}
+}
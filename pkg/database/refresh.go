@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// errRefreshToken возвращается RotateRefreshToken для неизвестного, истекшего или отозванного токена
+var errRefreshToken = &Error{Kind: ErrInvalidToken, Msg: "рефреш токен недействителен или истек"}
+
+// errRefreshReuse возвращается RotateRefreshToken при повторном предъявлении использованного токена.
+// errors.Is выполняется и для ErrTokenReused, и для ErrInvalidToken
+var errRefreshReuse = &Error{Kind: ErrTokenReused, Msg: "рефреш токен использован повторно, сеанс отозван", Err: ErrInvalidToken}
+
+// HashTokenID возвращает SHA-256 идентификатора токена (jti) в шестнадцатеричном виде
+func HashTokenID(jti string) string {
+	sum := sha256.Sum256([]byte(jti))
+	return hex.EncodeToString(sum[:])
+}
+
+// refreshTokenColumns — колонки refresh_tokens в порядке scanRefreshToken
+const refreshTokenColumns = `token_hash, family_id, parent_hash, user_id, device, expires_at, used_at, revoked_at, created_at`
+
+// scanRefreshToken читает строку refresh_tokens, выбранную в порядке refreshTokenColumns
+func scanRefreshToken(row pgx.Row) (model.RefreshToken, error) {
+	var t model.RefreshToken
+	err := row.Scan(&t.TokenHash, &t.FamilyID, &t.ParentHash, &t.UserID, &t.Device, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt, &t.CreatedAt)
+	return t, err
+}
+
+// CreateRefreshToken сохраняет первый рефреш токен нового семейства, выданный при входе пользователя с устройства device
+func (db *Store) CreateRefreshToken(ctx context.Context, userID, jti, device string, expiresAt time.Time) (model.RefreshToken, error) {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+	defer tx.rollback(ctx)
+
+	var exists bool
+	err = tx.conn().QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = false)`, userID).Scan(&exists)
+	if err != nil {
+		return model.RefreshToken{}, wrapError("ошибка получения пользователя", err)
+	}
+	if !exists {
+		return model.RefreshToken{}, NotFound("пользователь с ID %s не найден", userID)
+	}
+
+	query := `INSERT INTO refresh_tokens (token_hash, family_id, user_id, device, expires_at)
+			  VALUES ($1, gen_random_uuid(), $2, $3, $4)
+			  RETURNING ` + refreshTokenColumns
+	token, err := scanRefreshToken(tx.conn().QueryRow(ctx, query, HashTokenID(jti), userID, device, expiresAt))
+	if err != nil {
+		return model.RefreshToken{}, wrapError("ошибка сохранения рефреш токена", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, fmt.Sprintf("Начат сеанс на устройстве %q", device))
+	if err != nil {
+		return model.RefreshToken{}, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken помечает токен oldJTI использованным и сохраняет в том же семействе новый токен newJTI.
+// Если oldJTI уже использован, все семейство отзывается и возвращается ошибка категории ErrTokenReused:
+// значит, токен был похищен и его предъявили дважды.
+// Внутри WithTx отзыв выполняется в транзакции вызывающего кода: чтобы он сохранился,
+// транзакцию нужно зафиксировать и при этой ошибке
+func (db *Store) RotateRefreshToken(ctx context.Context, oldJTI, newJTI string, expiresAt time.Time) (model.RefreshToken, error) {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+	defer tx.rollback(ctx)
+
+	query := `SELECT ` + refreshTokenColumns + `, expires_at <= now()
+			  FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	var (
+		old     model.RefreshToken
+		expired bool
+	)
+	err = tx.conn().QueryRow(ctx, query, HashTokenID(oldJTI)).Scan(&old.TokenHash, &old.FamilyID, &old.ParentHash,
+		&old.UserID, &old.Device, &old.ExpiresAt, &old.UsedAt, &old.RevokedAt, &old.CreatedAt, &expired)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.RefreshToken{}, errRefreshToken
+		}
+		return model.RefreshToken{}, wrapError("ошибка получения рефреш токена", err)
+	}
+	if old.RevokedAt != nil || expired {
+		return model.RefreshToken{}, errRefreshToken
+	}
+
+	if old.UsedAt != nil {
+		if err := tx.revokeReusedFamily(ctx, old); err != nil {
+			return model.RefreshToken{}, err
+		}
+		if err := tx.commit(ctx); err != nil {
+			return model.RefreshToken{}, err
+		}
+		return model.RefreshToken{}, errRefreshReuse
+	}
+
+	var active bool
+	err = tx.conn().QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_deleted = false)`, old.UserID).Scan(&active)
+	if err != nil {
+		return model.RefreshToken{}, wrapError("ошибка получения пользователя", err)
+	}
+	if !active {
+		return model.RefreshToken{}, errRefreshToken
+	}
+
+	if _, err := tx.conn().Exec(ctx, `UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1`, old.TokenHash); err != nil {
+		return model.RefreshToken{}, wrapError("ошибка обновления рефреш токена", err)
+	}
+	query = `INSERT INTO refresh_tokens (token_hash, family_id, parent_hash, user_id, device, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 RETURNING ` + refreshTokenColumns
+	token, err := scanRefreshToken(tx.conn().QueryRow(ctx, query, HashTokenID(newJTI), old.FamilyID, old.TokenHash, old.UserID, old.Device, expiresAt))
+	if err != nil {
+		return model.RefreshToken{}, wrapError("ошибка сохранения рефреш токена", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+// revokeReusedFamily отзывает семейство повторно предъявленного токена и записывает это в журнал
+func (db *Store) revokeReusedFamily(ctx context.Context, reused model.RefreshToken) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	if err := tx.revokeRefreshFamily(ctx, reused.FamilyID); err != nil {
+		return err
+	}
+	// Логирование действия
+	err = tx.LogAction(ctx, reused.UserID, fmt.Sprintf("Повторное использование рефреш токена на устройстве %q, сеанс отозван", reused.Device))
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+	return tx.commit(ctx)
+}
+
+// RevokeRefreshFamily отзывает все токены семейства, например при выходе пользователя
+func (db *Store) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	var userID, device string
+	err = tx.conn().QueryRow(ctx, `SELECT user_id, device FROM refresh_tokens WHERE family_id = $1 LIMIT 1`, familyID).Scan(&userID, &device)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return NotFound("сеанс %s не найден", familyID)
+		}
+		return wrapError("ошибка получения сеанса", err)
+	}
+	if err := tx.revokeRefreshFamily(ctx, familyID); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, fmt.Sprintf("Сеанс на устройстве %q завершен", device))
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// revokeRefreshFamily отзывает неотозванные токены семейства
+func (db *Store) revokeRefreshFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := db.conn().Exec(ctx, query, familyID); err != nil {
+		return wrapError("ошибка отзыва сеанса", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+// UserLookup — операции с учетными записями, которые нужны для входа и сеансов (см. пакет user).
+// Подменить в тестах достаточно его, а не весь UserRepository
+type UserLookup interface {
+	GetUserByID(ctx context.Context, userID string) (model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (model.User, error)
+	UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error
+}
+
+// UserRepository описывает операции с учетными записями пользователей
+type UserRepository interface {
+	UserLookup
+	GetAllUsers(ctx context.Context) ([]model.User, error)
+	UpdateUser(ctx context.Context, userID string, patch model.UserPatch) (model.User, error)
+	ListUsers(ctx context.Context, opts ListOptions) (Page[model.User], error)
+	SearchUsers(ctx context.Context, opts SearchOptions) (SearchResult[model.User], error)
+}
+
+// AdminRepository описывает операции с администраторами
+type AdminRepository interface {
+	CreateAdmin(ctx context.Context, username, password string, permissions map[string]interface{}) (string, error)
+	GetAdminByID(ctx context.Context, adminID string) (model.Admin, error)
+	UpdateAdminPermissions(ctx context.Context, adminID string, permissions map[string]interface{}) (model.Admin, error)
+	ListAdmins(ctx context.Context, opts ListOptions) (Page[model.Admin], error)
+	DeleteAdmin(ctx context.Context, adminID string) error
+}
+
+// ClientRepository описывает операции с клиентами
+type ClientRepository interface {
+	CreateClient(ctx context.Context, username, password, fullName, phoneNumber string) (string, error)
+	GetClientByID(ctx context.Context, clientID string) (model.Client, error)
+	UpdateClient(ctx context.Context, clientID string, patch model.ClientPatch) (model.Client, error)
+	ListClients(ctx context.Context, opts ListOptions) (Page[model.Client], error)
+	SearchClients(ctx context.Context, opts SearchOptions) (SearchResult[model.Client], error)
+	DeleteClient(ctx context.Context, clientID string) error
+}
+
+// ManagerRepository описывает операции с менеджерами
+type ManagerRepository interface {
+	CreateManager(ctx context.Context, username, password, fullName string, hireDate model.Date) (string, error)
+	GetManagerByID(ctx context.Context, managerID string) (model.Manager, error)
+	UpdateManager(ctx context.Context, managerID string, patch model.ManagerPatch) (model.Manager, error)
+	ListManagers(ctx context.Context, opts ListOptions) (Page[model.Manager], error)
+	SearchManagers(ctx context.Context, opts SearchOptions) (SearchResult[model.Manager], error)
+	DeleteManager(ctx context.Context, managerID string) error
+}
+
+// DeletedUserRepository описывает операции с логически удаленными пользователями
+type DeletedUserRepository interface {
+	RestoreUser(ctx context.Context, userID string) (model.User, error)
+	ListDeletedUsers(ctx context.Context) ([]model.User, error)
+	PurgeUser(ctx context.Context, userID string, opts PurgeOptions) error
+	PurgeDeletedOlderThan(ctx context.Context, age time.Duration) (int, error)
+}
+
+// CredentialRepository описывает смену и сброс паролей
+type CredentialRepository interface {
+	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
+	CreatePasswordResetToken(ctx context.Context, userID string, ttl time.Duration) (string, error)
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	TokensValidAfter(ctx context.Context, userID string) (time.Time, error)
+}
+
+// LoginAttemptRepository описывает учет неудачных попыток входа и временную блокировку.
+// Ключи строятся функциями UserLockoutKey и IPLockoutKey
+type LoginAttemptRepository interface {
+	LoginLockedUntil(ctx context.Context, keys ...string) (time.Time, error)
+	RegisterLoginFailure(ctx context.Context, key string, rule LockoutRule) (time.Time, error)
+	ResetLoginFailures(ctx context.Context, key string) error
+	PurgeExpiredLoginAttempts(ctx context.Context, olderThan time.Duration) (int, error)
+	UnlockUser(ctx context.Context, userID string) error
+}
+
+// MFARepository описывает хранение настроек двухфакторной аутентификации и кодов восстановления.
+// Проверку кодов выполняет пакет mfa
+type MFARepository interface {
+	GetMFA(ctx context.Context, userID string) (model.MFA, error)
+	SaveMFASecret(ctx context.Context, userID, secret string) error
+	ConfirmMFA(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+	UseMFAStep(ctx context.Context, userID string, step int64) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	DisableMFA(ctx context.Context, userID string) error
+}
+
+// RefreshTokenRepository описывает хранение семейств рефреш токенов. Методы принимают jti токена,
+// в хранилище сохраняется только его хеш (HashTokenID)
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, userID, jti, device string, expiresAt time.Time) (model.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldJTI, newJTI string, expiresAt time.Time) (model.RefreshToken, error)
+	RevokeRefreshFamily(ctx context.Context, familyID string) error
+}
+
+// RevocationRepository описывает отзыв токенов доступа до истечения срока.
+// Отзыв всех токенов пользователя сдвигает момент, возвращаемый CredentialRepository.TokensValidAfter
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, userID, jti string, expiresAt time.Time) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+	TokenRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeExpiredRevocations(ctx context.Context) (int, error)
+}
+
+// AuditLogRepository описывает запись действий пользователей в журнал user_logs.
+// Пустой userID — запись без привязки к пользователю, например попытка входа с неизвестным именем
+type AuditLogRepository interface {
+	LogAction(ctx context.Context, userID, action string) error
+}
+
+// TxRunner выполняет несколько операций репозиториев атомарно
+type TxRunner interface {
+	WithTx(ctx context.Context, fn func(tx Repos) error) error
+}
+
+// Repos объединяет все репозитории. Его реализуют Store и memstore.Store
+type Repos interface {
+	UserRepository
+	AdminRepository
+	ClientRepository
+	ManagerRepository
+	DeletedUserRepository
+	CredentialRepository
+	LoginAttemptRepository
+	MFARepository
+	RefreshTokenRepository
+	RevocationRepository
+	AuditLogRepository
+	TxRunner
+}
+
+// Проверяем на этапе компиляции, что Store реализует все репозитории
+var _ Repos = (*Store)(nil)
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RevokeToken отзывает токен с идентификатором jti до истечения его срока expiresAt
+func (db *Store) RevokeToken(ctx context.Context, userID, jti string, expiresAt time.Time) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	query := `INSERT INTO revoked_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)
+			  ON CONFLICT (token_hash) DO NOTHING`
+	if _, err := tx.conn().Exec(ctx, query, HashTokenID(jti), userID, expiresAt); err != nil {
+		return wrapError("ошибка отзыва токена", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Токен доступа отозван")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// RevokeAllForUser отзывает все выданные пользователю токены: токены доступа, выданные раньше текущего момента,
+// становятся недействительными (TokensValidAfter), а все сеансы рефреш токенов завершаются
+func (db *Store) RevokeAllForUser(ctx context.Context, userID string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	tag, err := tx.conn().Exec(ctx, `UPDATE users SET tokens_valid_after = now() WHERE id = $1`, userID)
+	if err != nil {
+		return wrapError("ошибка отзыва токенов пользователя", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return NotFound("пользователь с ID %s не найден", userID)
+	}
+	if err := tx.revokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Отозваны все токены пользователя")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+// TokenRevoked сообщает, отозван ли токен с идентификатором jti через RevokeToken
+func (db *Store) TokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := db.conn().QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_hash = $1)`, HashTokenID(jti)).Scan(&revoked)
+	if err != nil {
+		return false, wrapError("ошибка проверки отзыва токена", err)
+	}
+	return revoked, nil
+}
+
+// PurgeExpiredRevocations удаляет записи об отозванных токенах, срок действия которых истек,
+// и возвращает число удаленных записей
+func (db *Store) PurgeExpiredRevocations(ctx context.Context) (int, error) {
+	tag, err := db.conn().Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, wrapError("ошибка удаления истекших отозванных токенов", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// revokeAllTokens сдвигает момент, до которого токены пользователя недействительны, и завершает его сеансы.
+// Вызывается внутри транзакции
+func (db *Store) revokeAllTokens(ctx context.Context, userID string) error {
+	if _, err := db.conn().Exec(ctx, `UPDATE users SET tokens_valid_after = now() WHERE id = $1`, userID); err != nil {
+		return wrapError("ошибка отзыва токенов пользователя", err)
+	}
+	return db.revokeUserRefreshTokens(ctx, userID)
+}
+
+// revokeUserRefreshTokens отзывает все неотозванные рефреш токены пользователя
+func (db *Store) revokeUserRefreshTokens(ctx context.Context, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := db.conn().Exec(ctx, query, userID); err != nil {
+		return wrapError("ошибка отзыва сеансов пользователя", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrIncompatibleSchema — категория ошибки несовместимой схемы базы данных
+var ErrIncompatibleSchema = errors.New("схема базы данных несовместима с версией crmlib")
+
+// requiredFunctions — хранимые функции с сигнатурами, которые вызывает библиотека
+var requiredFunctions = []string{
+	"create_admin(text, text, jsonb)",
+	"create_client(text, text, text, text)",
+	"create_manager(text, text, text, date)",
+	"delete_admin(uuid)",
+	"delete_client(uuid)",
+	"delete_manager(uuid)",
+	"similarity(text, text)", // pg_trgm, миграция 0002_search
+}
+
+// requiredColumns — колонки, которые читают и изменяют запросы библиотеки
+var requiredColumns = map[string][]string{
+	"users":     {"id", "username", "password_hash", "role", "is_deleted", "created_at", "updated_at", "tokens_valid_after"},
+	"admins":    {"id", "permissions"},
+	"clients":   {"id", "full_name", "phone_number"},
+	"managers":  {"id", "full_name", "hire_date"},
+	"user_logs": {"id", "user_id", "action", "timestamp"},
+
+	"password_reset_tokens": {"id", "user_id", "token_hash", "expires_at", "used_at"},              // 0003_passwords
+	"login_attempts":        {"key", "failures", "last_failure_at", "locked_until"},                // 0004_login_attempts
+	"user_mfa":              {"user_id", "secret", "confirmed_at", "last_used_step", "created_at"}, // 0005_mfa
+	"mfa_recovery_codes":    {"id", "user_id", "code_hash", "used_at"},
+
+	"refresh_tokens": {"token_hash", "family_id", "parent_hash", "user_id", "device", "expires_at", "used_at", "revoked_at", "created_at"}, // 0006_refresh_tokens
+
+	"revoked_tokens": {"token_hash", "user_id", "expires_at", "revoked_at"}, // 0007_revoked_tokens
+}
+
+// SchemaError описывает расхождение схемы базы данных с ожидаемой библиотекой.
+// errors.Is(err, ErrIncompatibleSchema) == true
+type SchemaError struct {
+	ExpectedVersion int      // Версия схемы, которую ожидает библиотека
+	ActualVersion   int      // Версия из schema_migrations или 0, если таблицы нет
+	Missing         []string // Отсутствующие функции, колонки и версии
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%v (ожидается версия %d, в базе %d): отсутствуют %s",
+		ErrIncompatibleSchema, e.ExpectedVersion, e.ActualVersion, strings.Join(e.Missing, ", "))
+}
+
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrIncompatibleSchema
+}
+
+// CheckSchema проверяет, что в базе применены все встроенные миграции и существуют
+// все функции и колонки, которые использует библиотека. Если таблицы schema_migrations нет
+// (схема ведется вне crmlib), проверяются только функции и колонки
+func CheckSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	schemaErr := &SchemaError{ExpectedVersion: SchemaVersion()}
+
+	var hasVersionTable bool
+	err := pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&hasVersionTable)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки схемы: %w", err)
+	}
+	if hasVersionTable {
+		err := pool.QueryRow(ctx, `SELECT COALESCE(max(version), 0) FROM schema_migrations`).Scan(&schemaErr.ActualVersion)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения версии схемы: %w", err)
+		}
+		migrations, err := Migrations()
+		if err != nil {
+			return fmt.Errorf("ошибка чтения миграций: %w", err)
+		}
+		for _, m := range migrations {
+			if m.Version > schemaErr.ActualVersion {
+				schemaErr.Missing = append(schemaErr.Missing, fmt.Sprintf("миграция %04d_%s", m.Version, m.Name))
+			}
+		}
+	}
+
+	for _, fn := range requiredFunctions {
+		var exists bool
+		if err := pool.QueryRow(ctx, `SELECT to_regprocedure($1) IS NOT NULL`, fn).Scan(&exists); err != nil {
+			return fmt.Errorf("ошибка проверки функции %s: %w", fn, err)
+		}
+		if !exists {
+			schemaErr.Missing = append(schemaErr.Missing, "функция "+fn)
+		}
+	}
+
+	rows, err := pool.Query(ctx, `SELECT table_name, column_name FROM information_schema.columns
+								  WHERE table_schema = current_schema()`)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения колонок: %w", err)
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[table+"."+column] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Обходим таблицы в фиксированном порядке, чтобы сообщение об ошибке было стабильным
+	tables := make([]string, 0, len(requiredColumns))
+	for table := range requiredColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		for _, column := range requiredColumns[table] {
+			if !existing[table+"."+column] {
+				schemaErr.Missing = append(schemaErr.Missing, "колонка "+table+"."+column)
+			}
+		}
+	}
+
+	if len(schemaErr.Missing) > 0 {
+		return schemaErr
+	}
+	return nil
+}
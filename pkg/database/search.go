@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// Поиск использует расширение pg_trgm: оператор % отбирает строки с триграммным сходством
+// не ниже порога pg_trgm.similarity_threshold (по умолчанию 0.3), а similarity задает ранг.
+// Подстрока в имени дает ранг не ниже SubstringScore, совпадение последних цифр телефона — PhoneScore
+
+const (
+	// SimilarityThreshold совпадает со значением pg_trgm.similarity_threshold по умолчанию
+	SimilarityThreshold = 0.3
+	// SubstringScore — ранг записи, имя которой содержит запрос целиком
+	SubstringScore = 0.5
+	// PhoneScore — ранг клиента, номер телефона которого заканчивается цифрами запроса
+	PhoneScore = 1.0
+	// MinPhoneDigits — минимальное количество цифр в запросе для поиска по телефону
+	MinPhoneDigits = 3
+)
+
+// SearchOptions задает поисковый запрос и параметры страницы
+type SearchOptions struct {
+	Query          string // Часть имени пользователя, ФИО или последние цифры телефона
+	Role           string // Фильтр по роли. Учитывается только в SearchUsers
+	Limit          int    // Размер страницы. 0 — DefaultListLimit
+	Offset         int    // Смещение от начала результатов
+	IncludeDeleted bool   // Искать также среди логически удаленных записей
+}
+
+// SearchHit — найденная запись и ее ранг от 0 до 1
+type SearchHit[T any] struct {
+	Item  T
+	Score float64
+}
+
+// SearchResult содержит страницу результатов, упорядоченных по убыванию ранга.
+// NextOffset равен 0, если страница последняя
+type SearchResult[T any] struct {
+	Hits       []SearchHit[T]
+	NextOffset int
+}
+
+// SearchTerms — поисковый запрос, приведенный к виду, в котором он сравнивается с данными
+type SearchTerms struct {
+	Text   string // Запрос в нижнем регистре без крайних пробелов
+	Digits string // Цифры запроса. Пустая строка, если цифр меньше MinPhoneDigits
+}
+
+// NormalizeSearch проверяет параметры поиска и приводит запрос к SearchTerms
+func NormalizeSearch(opts SearchOptions) (SearchOptions, SearchTerms, error) {
+	var terms SearchTerms
+	terms.Text = strings.ToLower(strings.TrimSpace(opts.Query))
+	if terms.Text == "" {
+		return opts, terms, InvalidInput("пустой поисковый запрос")
+	}
+	terms.Digits = NormalizePhone(terms.Text)
+	if len(terms.Digits) < MinPhoneDigits {
+		terms.Digits = ""
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultListLimit
+	}
+	if opts.Limit > MaxListLimit {
+		opts.Limit = MaxListLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	return opts, terms, nil
+}
+
+// NormalizePhone оставляет в номере телефона только цифры
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// searchPage обрезает лишнюю запись и вычисляет смещение следующей страницы
+func searchPage[T any](hits []SearchHit[T], opts SearchOptions) SearchResult[T] {
+	result := SearchResult[T]{Hits: hits}
+	if len(hits) > opts.Limit {
+		result.Hits = hits[:opts.Limit]
+		result.NextOffset = opts.Offset + opts.Limit
+	}
+	return result
+}
+
+// nameMatch возвращает условие отбора и выражение ранга для текстовых колонок.
+// $1 — запрос в нижнем регистре, $2 — он же в виде экранированного шаблона LIKE.
+// Оба условия используют триграммные индексы из миграции 0002_search
+func nameMatch(columns ...string) (where, score string) {
+	var conditions, scores []string
+	for _, c := range columns {
+		conditions = append(conditions,
+			fmt.Sprintf("%s %% $1", c),
+			fmt.Sprintf("%s ILIKE '%%' || $2 || '%%'", c))
+		scores = append(scores,
+			fmt.Sprintf("similarity(%s, $1)", c),
+			fmt.Sprintf("CASE WHEN %s ILIKE '%%' || $2 || '%%' THEN %v ELSE 0 END", c, SubstringScore))
+	}
+	return strings.Join(conditions, " OR "), strings.Join(scores, ", ")
+}
+
+// SearchUsers ищет пользователей по имени пользователя
+func (db *Store) SearchUsers(ctx context.Context, opts SearchOptions) (SearchResult[model.User], error) {
+	opts, terms, err := NormalizeSearch(opts)
+	if err != nil {
+		return SearchResult[model.User]{}, err
+	}
+
+	where, score := nameMatch("u.username")
+	query := fmt.Sprintf(`SELECT u.id, u.username, u.role, u.is_deleted, u.created_at, u.updated_at,
+					 GREATEST(%s)::float8 AS score
+			  FROM users u
+			  WHERE (%s) AND ($3 OR u.is_deleted = false) AND ($4 = '' OR u.role = $4)
+			  ORDER BY score DESC, u.id
+			  LIMIT $5 OFFSET $6`, score, where)
+
+	hits, err := collect(ctx, db, query, []interface{}{terms.Text, escapeLike(terms.Text), opts.IncludeDeleted, opts.Role, opts.Limit + 1, opts.Offset},
+		func(row pgx.Rows) (SearchHit[model.User], error) {
+			var hit SearchHit[model.User]
+
+			user := &hit.Item
+			err := row.Scan(&user.ID, &user.Username, &user.Role, &user.IsDeleted, &user.CreatedAt, &user.UpdatedAt, &hit.Score)
+			return hit, err
+		})
+	if err != nil {
+		return SearchResult[model.User]{}, fmt.Errorf("ошибка поиска пользователей: %w", err)
+	}
+
+	return searchPage(hits, opts), nil
+}
+
+// SearchClients ищет клиентов по имени пользователя, ФИО и последним цифрам номера телефона
+func (db *Store) SearchClients(ctx context.Context, opts SearchOptions) (SearchResult[model.Client], error) {
+	opts, terms, err := NormalizeSearch(opts)
+	if err != nil {
+		return SearchResult[model.Client]{}, err
+	}
+
+	// $3 — цифры запроса, номер телефона сравнивается без форматирования
+	where, score := nameMatch("u.username", "c.full_name")
+	query := fmt.Sprintf(`SELECT u.id, u.username, c.full_name, c.phone_number, u.is_deleted, u.created_at, u.updated_at,
+					 GREATEST(%s,
+						 CASE WHEN $3 <> '' AND regexp_replace(c.phone_number, '\D', '', 'g') LIKE '%%' || $3 THEN %v ELSE 0 END
+					 )::float8 AS score
+			  FROM clients c
+			  JOIN users u ON c.id = u.id
+			  WHERE (%s OR ($3 <> '' AND regexp_replace(c.phone_number, '\D', '', 'g') LIKE '%%' || $3))
+				AND ($4 OR u.is_deleted = false)
+			  ORDER BY score DESC, u.id
+			  LIMIT $5 OFFSET $6`, score, PhoneScore, where)
+
+	hits, err := collect(ctx, db, query, []interface{}{terms.Text, escapeLike(terms.Text), terms.Digits, opts.IncludeDeleted, opts.Limit + 1, opts.Offset},
+		func(row pgx.Rows) (SearchHit[model.Client], error) {
+			var hit SearchHit[model.Client]
+
+			client := &hit.Item
+			err := row.Scan(&client.ID, &client.Username, &client.FullName, &client.PhoneNumber, &client.IsDeleted, &client.CreatedAt, &client.UpdatedAt, &hit.Score)
+			return hit, err
+		})
+	if err != nil {
+		return SearchResult[model.Client]{}, fmt.Errorf("ошибка поиска клиентов: %w", err)
+	}
+
+	return searchPage(hits, opts), nil
+}
+
+// SearchManagers ищет менеджеров по имени пользователя и ФИО
+func (db *Store) SearchManagers(ctx context.Context, opts SearchOptions) (SearchResult[model.Manager], error) {
+	opts, terms, err := NormalizeSearch(opts)
+	if err != nil {
+		return SearchResult[model.Manager]{}, err
+	}
+
+	where, score := nameMatch("u.username", "m.full_name")
+	query := fmt.Sprintf(`SELECT u.id, u.username, m.full_name, m.hire_date, u.is_deleted, u.created_at, u.updated_at,
+					 GREATEST(%s)::float8 AS score
+			  FROM managers m
+			  JOIN users u ON m.id = u.id
+			  WHERE (%s) AND ($3 OR u.is_deleted = false)
+			  ORDER BY score DESC, u.id
+			  LIMIT $4 OFFSET $5`, score, where)
+
+	hits, err := collect(ctx, db, query, []interface{}{terms.Text, escapeLike(terms.Text), opts.IncludeDeleted, opts.Limit + 1, opts.Offset},
+		func(row pgx.Rows) (SearchHit[model.Manager], error) {
+			var hit SearchHit[model.Manager]
+
+			manager := &hit.Item
+			err := row.Scan(&manager.ID, &manager.Username, &manager.FullName, &manager.HireDate, &manager.IsDeleted, &manager.CreatedAt, &manager.UpdatedAt, &hit.Score)
+			return hit, err
+		})
+	if err != nil {
+		return SearchResult[model.Manager]{}, fmt.Errorf("ошибка поиска менеджеров: %w", err)
+	}
+
+	return searchPage(hits, opts), nil
+}
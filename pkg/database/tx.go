@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier — общие методы пула соединений и транзакции
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// conn возвращает транзакцию, если хранилище работает внутри WithTx, иначе пул соединений
+func (db *Store) conn() querier {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.Pool
+}
+
+// begin начинает транзакцию и возвращает хранилище, привязанное к ней.
+// Внутри уже открытой транзакции создается точка сохранения
+func (db *Store) begin(ctx context.Context) (*Store, error) {
+	tx, err := db.conn().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	return &Store{Pool: db.Pool, tx: tx}, nil
+}
+
+// rollback откатывает транзакцию. После commit вызов ничего не делает
+func (db *Store) rollback(ctx context.Context) {
+	_ = db.tx.Rollback(ctx)
+}
+
+func (db *Store) commit(ctx context.Context) error {
+	if err := db.tx.Commit(ctx); err != nil {
+		return wrapError("ошибка фиксации транзакции", err)
+	}
+	return nil
+}
+
+// WithTx выполняет fn в одной транзакции: все изменения и записи журнала внутри fn
+// фиксируются вместе, если fn вернула nil, и откатываются при ошибке.
+// Вложенный вызов WithTx создает точку сохранения
+func (db *Store) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit(ctx)
+}
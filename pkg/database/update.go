@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// errEmptyPatch возвращается, если патч не изменяет ни одного поля
+var errEmptyPatch = &Error{Kind: ErrInvalidInput, Msg: "нет полей для обновления"}
+
+func (db *Store) UpdateUser(ctx context.Context, userID string, patch model.UserPatch) (model.User, error) {
+	fields := patch.Fields()
+	if len(fields) == 0 {
+		return model.User{}, errEmptyPatch
+	}
+	if err := patch.Validate(); err != nil {
+		return model.User{}, Invalid(err)
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.User{}, err
+	}
+	defer tx.rollback(ctx)
+
+	query := `UPDATE users u
+			  SET username = COALESCE($2, u.username), updated_at = now()
+			  FROM (SELECT username FROM users WHERE id = $1 FOR UPDATE) old
+			  WHERE u.id = $1 AND u.is_deleted = false
+			  RETURNING u.id, u.username, u.role, u.password_hash, u.created_at, u.updated_at, old.username`
+
+	var user model.User
+	var oldUsername string
+
+	err = tx.conn().QueryRow(ctx, query, userID, patch.Username).Scan(&user.ID, &user.Username, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt, &oldUsername)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, NotFound("пользователь с ID %s не найден", userID)
+		}
+		return user, wrapError("ошибка обновления пользователя", err)
+	}
+
+	// Счетчик неудачных попыток входа хранится по имени пользователя и переходит к новому имени.
+	// Попытки входа под новым именем, сделанные до переименования, к пользователю не относятся
+	if user.Username != oldUsername {
+		oldKey, newKey := UserLockoutKey(oldUsername), UserLockoutKey(user.Username)
+		if _, err := tx.conn().Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, newKey); err != nil {
+			return model.User{}, wrapError("ошибка переноса попыток входа", err)
+		}
+		if _, err := tx.conn().Exec(ctx, `UPDATE login_attempts SET key = $2 WHERE key = $1`, oldKey, newKey); err != nil {
+			return model.User{}, wrapError("ошибка переноса попыток входа", err)
+		}
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, fmt.Sprintf("Пользователь обновлен: %s", strings.Join(fields, ", ")))
+	if err != nil {
+		return user, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// UpdatePasswordHash заменяет хеш пароля пользователя, например при пересчете хеша после входа.
+// updated_at не изменяется: данные пользователя остаются прежними
+func (db *Store) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback(ctx)
+
+	query := `UPDATE users SET password_hash = $2 WHERE id = $1 AND is_deleted = false`
+
+	tag, err := tx.conn().Exec(ctx, query, userID, passwordHash)
+	if err != nil {
+		return wrapError("ошибка обновления хеша пароля", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return NotFound("пользователь с ID %s не найден", userID)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, userID, "Хеш пароля пересчитан")
+	if err != nil {
+		return fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	return tx.commit(ctx)
+}
+
+func (db *Store) UpdateAdminPermissions(ctx context.Context, adminID string, permissions map[string]interface{}) (model.Admin, error) {
+	if err := model.ValidatePermissions(permissions); err != nil {
+		return model.Admin{}, Invalid(err)
+	}
+
+	// Преобразование карты permissions в JSONB формат
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return model.Admin{}, &Error{Kind: ErrInvalidInput, Msg: "ошибка преобразования permissions в JSON", Err: err}
+	}
+
+	// Обновляем users и admins одним запросом, чтобы updated_at менялся только у администраторов
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.Admin{}, err
+	}
+	defer tx.rollback(ctx)
+
+	query := `WITH u AS (
+				  UPDATE users SET updated_at = now()
+				  WHERE id = $1 AND role = 'admin' AND is_deleted = false
+				  RETURNING id, username, created_at, updated_at
+			  )
+			  UPDATE admins a SET permissions = $2
+			  FROM u WHERE a.id = u.id
+			  RETURNING u.id, u.username, a.permissions, u.created_at, u.updated_at`
+
+	var admin model.Admin
+
+	err = tx.conn().QueryRow(ctx, query, adminID, permissionsJSON).Scan(&admin.ID, &admin.Username, &admin.Permissions, &admin.CreatedAt, &admin.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return admin, NotFound("администратор с ID %s не найден", adminID)
+		}
+		return admin, wrapError("ошибка обновления прав администратора", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, adminID, "Права администратора обновлены: permissions")
+	if err != nil {
+		return admin, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.Admin{}, err
+	}
+
+	return admin, nil
+}
+
+func (db *Store) UpdateClient(ctx context.Context, clientID string, patch model.ClientPatch) (model.Client, error) {
+	fields := patch.Fields()
+	if len(fields) == 0 {
+		return model.Client{}, errEmptyPatch
+	}
+	if err := patch.Validate(); err != nil {
+		return model.Client{}, Invalid(err)
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.Client{}, err
+	}
+	defer tx.rollback(ctx)
+
+	query := `WITH u AS (
+				  UPDATE users SET updated_at = now()
+				  WHERE id = $1 AND role = 'client' AND is_deleted = false
+				  RETURNING id, username, created_at, updated_at
+			  )
+			  UPDATE clients c
+			  SET full_name = COALESCE($2, c.full_name), phone_number = COALESCE($3, c.phone_number)
+			  FROM u WHERE c.id = u.id
+			  RETURNING u.id, u.username, c.full_name, c.phone_number, u.created_at, u.updated_at`
+
+	var client model.Client
+
+	err = tx.conn().QueryRow(ctx, query, clientID, patch.FullName, patch.PhoneNumber).Scan(&client.ID, &client.Username, &client.FullName, &client.PhoneNumber, &client.CreatedAt, &client.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return client, NotFound("клиент с ID %s не найден", clientID)
+		}
+		return client, wrapError("ошибка обновления клиента", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, clientID, fmt.Sprintf("Клиент обновлен: %s", strings.Join(fields, ", ")))
+	if err != nil {
+		return client, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.Client{}, err
+	}
+
+	return client, nil
+}
+
+func (db *Store) UpdateManager(ctx context.Context, managerID string, patch model.ManagerPatch) (model.Manager, error) {
+	fields := patch.Fields()
+	if len(fields) == 0 {
+		return model.Manager{}, errEmptyPatch
+	}
+	if err := patch.Validate(); err != nil {
+		return model.Manager{}, Invalid(err)
+	}
+
+	// Изменение и запись в журнал фиксируются в одной транзакции
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return model.Manager{}, err
+	}
+	defer tx.rollback(ctx)
+
+	query := `WITH u AS (
+				  UPDATE users SET updated_at = now()
+				  WHERE id = $1 AND role = 'manager' AND is_deleted = false
+				  RETURNING id, username, created_at, updated_at
+			  )
+			  UPDATE managers m
+			  SET full_name = COALESCE($2, m.full_name), hire_date = COALESCE($3, m.hire_date)
+			  FROM u WHERE m.id = u.id
+			  RETURNING u.id, u.username, m.full_name, m.hire_date, u.created_at, u.updated_at`
+
+	var manager model.Manager
+
+	err = tx.conn().QueryRow(ctx, query, managerID, patch.FullName, patch.HireDate).Scan(&manager.ID, &manager.Username, &manager.FullName, &manager.HireDate, &manager.CreatedAt, &manager.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return manager, NotFound("менеджер с ID %s не найден", managerID)
+		}
+		return manager, wrapError("ошибка обновления менеджера", err)
+	}
+
+	// Логирование действия
+	err = tx.LogAction(ctx, managerID, fmt.Sprintf("Менеджер обновлен: %s", strings.Join(fields, ", ")))
+	if err != nil {
+		return manager, fmt.Errorf("ошибка записи лога: %w", err)
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return model.Manager{}, err
+	}
+
+	return manager, nil
+}
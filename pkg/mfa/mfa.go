@@ -0,0 +1,184 @@
+// Package mfa реализует двухфакторную аутентификацию: одноразовые пароли по времени (TOTP, RFC 6238)
+// и одноразовые коды восстановления. Настройки и хеши кодов хранятся через database.MFARepository
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+)
+
+var (
+	// ErrInvalidCode возвращается для неверного, просроченного или уже использованного кода
+	ErrInvalidCode = errors.New("неверный код двухфакторной аутентификации")
+	// ErrNotEnrolled возвращается, если двухфакторная аутентификация пользователя не подключена
+	ErrNotEnrolled = errors.New("двухфакторная аутентификация не подключена")
+)
+
+// Enrollment — данные для подключения приложения-аутентификатора
+type Enrollment struct {
+	Secret string // Секрет для ручного ввода
+	URI    string // otpauth:// URI для QR-кода
+}
+
+// Service подключает, проверяет и отключает двухфакторную аутентификацию
+type Service struct {
+	repo          database.MFARepository
+	totp          TOTP
+	recoveryCodes int
+	now           func() time.Time
+}
+
+// Option настраивает Service
+type Option func(*Service)
+
+// WithTOTP задает параметры TOTP вместо DefaultTOTP. Некорректные параметры (см. TOTP.CheckParams) New не принимает
+func WithTOTP(totp TOTP) Option {
+	return func(s *Service) {
+		s.totp = totp
+	}
+}
+
+// WithRecoveryCodes задает число выдаваемых кодов восстановления вместо DefaultRecoveryCodes
+func WithRecoveryCodes(n int) Option {
+	return func(s *Service) {
+		s.recoveryCodes = n
+	}
+}
+
+// New создает Service поверх хранилища. Возвращает ошибку, если параметры TOTP некорректны
+func New(repo database.MFARepository, opts ...Option) (*Service, error) {
+	s := &Service{repo: repo, totp: DefaultTOTP, recoveryCodes: DefaultRecoveryCodes, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.totp.CheckParams(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// TOTP возвращает параметры TOTP сервиса
+func (s *Service) TOTP() TOTP {
+	return s.totp
+}
+
+// Enroll начинает подключение: генерирует новый секрет и возвращает его вместе с otpauth URI.
+// Подключение вступает в силу после Confirm. Если двухфакторная аутентификация уже подключена,
+// возвращается ошибка категории database.ErrConflict
+func (s *Service) Enroll(ctx context.Context, userID, account string) (Enrollment, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("ошибка генерации секрета: %w", err)
+	}
+	if err := s.repo.SaveMFASecret(ctx, userID, secret); err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, URI: s.totp.URI(secret, account)}, nil
+}
+
+// Confirm завершает подключение кодом из приложения и возвращает коды восстановления.
+// Коды показываются пользователю один раз, в хранилище остаются только их хеши
+func (s *Service) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	if m.Confirmed() {
+		return nil, &database.Error{Kind: database.ErrConflict, Msg: fmt.Sprintf("двухфакторная аутентификация пользователя с ID %s уже подключена", userID)}
+	}
+
+	step, err := s.totp.Validate(m.Secret, code, s.now())
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := GenerateRecoveryCodes(s.recoveryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации кодов восстановления: %w", err)
+	}
+	if err := s.repo.ConfirmMFA(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Enabled сообщает, подключена ли и подтверждена двухфакторная аутентификация пользователя
+func (s *Service) Enabled(ctx context.Context, userID string) (bool, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return m.Confirmed(), nil
+}
+
+// Verify проверяет код из приложения или код восстановления. Каждый код принимается один раз:
+// для TOTP запоминается последний принятый шаг, код восстановления помечается использованным
+func (s *Service) Verify(ctx context.Context, userID, code string) error {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotEnrolled
+		}
+		return err
+	}
+	if !m.Confirmed() {
+		return ErrNotEnrolled
+	}
+
+	if len(normalizeCode(code)) == s.totp.Digits {
+		step, err := s.totp.Validate(m.Secret, code, s.now())
+		if err != nil {
+			return err
+		}
+		if err := s.repo.UseMFAStep(ctx, userID, step); err != nil {
+			if errors.Is(err, database.ErrInvalidToken) {
+				return fmt.Errorf("%w: код уже использован", ErrInvalidCode)
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := s.repo.UseRecoveryCode(ctx, userID, HashRecoveryCode(code)); err != nil {
+		if errors.Is(err, database.ErrInvalidToken) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми и возвращает их
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes, hashes, err := GenerateRecoveryCodes(s.recoveryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации кодов восстановления: %w", err)
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable отключает двухфакторную аутентификацию пользователя
+func (s *Service) Disable(ctx context.Context, userID string) error {
+	if err := s.repo.DisableMFA(ctx, userID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotEnrolled
+		}
+		return err
+	}
+	return nil
+}
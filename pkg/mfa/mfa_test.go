@@ -0,0 +1,115 @@
+package mfa_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/database/memstore"
+	"github.com/Maden-in-haven/crmlib/pkg/mfa"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+)
+
+// hireDate — дата приема на работу менеджеров, создаваемых в тестах
+var hireDate = model.Date{Year: 2024, Month: time.March, Day: 1}
+
+// totpCode возвращает код TOTP для шага, отстоящего на offset от текущего
+func totpCode(t *testing.T, totp mfa.TOTP, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	return code
+}
+
+func TestService(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	service, err := mfa.New(r)
+	if err != nil {
+		t.Fatalf("mfa.New: %v", err)
+	}
+	totp := service.TOTP()
+
+	id, err := r.CreateManager(ctx, "manager", "secret-password", "Менеджер", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+	if enabled, err := service.Enabled(ctx, id); err != nil || enabled {
+		t.Fatalf("Enabled до подключения = %v, %v", enabled, err)
+	}
+	if err := service.Verify(ctx, id, "000000"); !errors.Is(err, mfa.ErrNotEnrolled) {
+		t.Errorf("Verify до подключения: ожидалась ErrNotEnrolled, получено %v", err)
+	}
+
+	enrollment, err := service.Enroll(ctx, id, "manager")
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, "secret="+enrollment.Secret) {
+		t.Errorf("URI = %q", enrollment.URI)
+	}
+	// До подтверждения второй фактор не действует
+	if enabled, err := service.Enabled(ctx, id); err != nil || enabled {
+		t.Fatalf("Enabled до подтверждения = %v, %v", enabled, err)
+	}
+
+	if _, err := service.Confirm(ctx, id, "not-a-code"); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("Confirm с неверным кодом: ожидалась ErrInvalidCode, получено %v", err)
+	}
+	codes, err := service.Confirm(ctx, id, totpCode(t, totp, enrollment.Secret, 0))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if len(codes) != mfa.DefaultRecoveryCodes {
+		t.Fatalf("выдано %d кодов восстановления, ожидалось %d", len(codes), mfa.DefaultRecoveryCodes)
+	}
+	if _, err := service.Enroll(ctx, id, "manager"); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("повторный Enroll: ожидалась ErrConflict, получено %v", err)
+	}
+
+	// Код, принятый при подтверждении, повторно не принимается
+	if err := service.Verify(ctx, id, totpCode(t, totp, enrollment.Secret, 0)); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("повторный код: ожидалась ErrInvalidCode, получено %v", err)
+	}
+	// Допускается расхождение часов на шаг
+	if err := service.Verify(ctx, id, totpCode(t, totp, enrollment.Secret, 1)); err != nil {
+		t.Errorf("Verify кода следующего шага: %v", err)
+	}
+	if err := service.Verify(ctx, id, totpCode(t, totp, enrollment.Secret, 5)); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("код за пределами окна: ожидалась ErrInvalidCode, получено %v", err)
+	}
+
+	// Код восстановления принимается один раз, без учета регистра
+	if err := service.Verify(ctx, id, strings.ToUpper(codes[0])); err != nil {
+		t.Errorf("Verify кода восстановления: %v", err)
+	}
+	if err := service.Verify(ctx, id, codes[0]); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("повторный код восстановления: ожидалась ErrInvalidCode, получено %v", err)
+	}
+
+	fresh, err := service.RegenerateRecoveryCodes(ctx, id)
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if err := service.Verify(ctx, id, codes[1]); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("старый код восстановления: ожидалась ErrInvalidCode, получено %v", err)
+	}
+	if err := service.Verify(ctx, id, fresh[0]); err != nil {
+		t.Errorf("Verify нового кода восстановления: %v", err)
+	}
+
+	if err := service.Disable(ctx, id); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if enabled, err := service.Enabled(ctx, id); err != nil || enabled {
+		t.Errorf("Enabled после отключения = %v, %v", enabled, err)
+	}
+	if err := service.Disable(ctx, id); !errors.Is(err, mfa.ErrNotEnrolled) {
+		t.Errorf("повторный Disable: ожидалась ErrNotEnrolled, получено %v", err)
+	}
+}
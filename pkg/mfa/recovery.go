@@ -0,0 +1,38 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// DefaultRecoveryCodes — число кодов восстановления, выдаваемых при подключении
+const DefaultRecoveryCodes = 10
+
+// recoveryCodeSize — длина кода восстановления в байтах (80 бит, 16 символов base32)
+const recoveryCodeSize = 10
+
+// GenerateRecoveryCodes генерирует n одноразовых кодов восстановления вида xxxx-xxxx-xxxx-xxxx и их хеши для хранения
+func GenerateRecoveryCodes(n int) (codes, hashes []string, err error) {
+	codes = make([]string, n)
+	hashes = make([]string, n)
+	for i := range codes {
+		var b [recoveryCodeSize]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b[:]))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode возвращает SHA-256 кода восстановления в шестнадцатеричном виде.
+// Регистр, пробелы и дефисы не учитываются. Код случайный и длинный, поэтому медленный хеш не нужен
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(normalizeCode(code))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secretEncoding — кодировка секрета в otpauth URI: base32 без выравнивания
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// secretSize — длина секрета в байтах, рекомендованная RFC 4226 для HMAC-SHA1
+const secretSize = 20
+
+// Допустимое число цифр в коде: RFC 4226 требует не меньше 6, 9 и больше не поддерживают приложения-аутентификаторы
+const (
+	MinTOTPDigits = 6
+	MaxTOTPDigits = 8
+)
+
+// TOTP задает параметры одноразовых паролей по времени (RFC 6238, HMAC-SHA1)
+type TOTP struct {
+	Issuer string        // Издатель в otpauth URI, отображается в приложении-аутентификаторе
+	Digits int           // Число цифр в коде
+	Period time.Duration // Длительность шага
+	Skew   int           // Допустимое расхождение часов в шагах в каждую сторону
+}
+
+// DefaultTOTP — параметры, которые поддерживают все распространенные приложения-аутентификаторы
+var DefaultTOTP = TOTP{Issuer: "CRM", Digits: 6, Period: 30 * time.Second, Skew: 1}
+
+// CheckParams проверяет параметры: Digits от MinTOTPDigits до MaxTOTPDigits, Period — целое число секунд
+// не меньше секунды, Skew не отрицателен
+func (t TOTP) CheckParams() error {
+	if t.Digits < MinTOTPDigits || t.Digits > MaxTOTPDigits {
+		return fmt.Errorf("некорректные параметры TOTP: число цифр %d вне диапазона %d..%d", t.Digits, MinTOTPDigits, MaxTOTPDigits)
+	}
+	if t.Period < time.Second || t.Period%time.Second != 0 {
+		return fmt.Errorf("некорректные параметры TOTP: шаг %s должен быть целым числом секунд", t.Period)
+	}
+	if t.Skew < 0 {
+		return errors.New("некорректные параметры TOTP: отрицательное расхождение часов")
+	}
+	return nil
+}
+
+// GenerateSecret генерирует случайный секрет TOTP в base32
+func GenerateSecret() (string, error) {
+	var b [secretSize]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(b[:]), nil
+}
+
+// URI возвращает otpauth:// URI для QR-кода. account — имя учетной записи, которое увидит пользователь
+func (t TOTP) URI(secret, account string) string {
+	label := account
+	if t.Issuer != "" {
+		label = t.Issuer + ":" + account
+	}
+	params := url.Values{}
+	params.Set("secret", secret)
+	if t.Issuer != "" {
+		params.Set("issuer", t.Issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(t.Digits))
+	params.Set("period", strconv.Itoa(int(t.Period/time.Second)))
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + params.Encode()
+}
+
+// Step возвращает номер шага TOTP для момента at. Параметры должны быть корректны, см. CheckParams
+func (t TOTP) Step(at time.Time) int64 {
+	return at.Unix() / int64(t.Period/time.Second)
+}
+
+// Code возвращает код для шага step (RFC 4226, раздел 5.3)
+func (t TOTP) Code(secret string, step int64) (string, error) {
+	if err := t.CheckParams(); err != nil {
+		return "", err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.code(key, step), nil
+}
+
+// Validate проверяет код на моменте at с допуском Skew шагов и возвращает номер шага, которому он соответствует.
+// Повторное использование кода не проверяется: номер шага нужно сохранить через database.MFARepository.UseMFAStep
+func (t TOTP) Validate(secret, code string, at time.Time) (int64, error) {
+	if err := t.CheckParams(); err != nil {
+		return 0, err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = normalizeCode(code)
+	if len(code) != t.Digits {
+		return 0, ErrInvalidCode
+	}
+
+	// Проверяем все шаги окна, чтобы время ответа не зависело от того, какой из них совпал
+	current := t.Step(at)
+	var matched int64
+	found := 0
+	for i := -t.Skew; i <= t.Skew; i++ {
+		step := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(t.code(key, step)), []byte(code)) == 1 && found == 0 {
+			matched, found = step, 1
+		}
+	}
+	if found == 0 {
+		return 0, ErrInvalidCode
+	}
+	return matched, nil
+}
+
+func (t TOTP) code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Динамическое усечение
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, value%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный секрет TOTP: %w", err)
+	}
+	return key, nil
+}
+
+// normalizeCode удаляет пробелы и дефисы, которые пользователь мог ввести вместе с кодом
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+}
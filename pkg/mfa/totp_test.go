@@ -0,0 +1,76 @@
+package mfa_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database/memstore"
+	"github.com/Maden-in-haven/crmlib/pkg/mfa"
+)
+
+// TestTOTPRFC6238 проверяет коды по тестовым векторам RFC 6238, приложение B (HMAC-SHA1)
+func TestTOTPRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	totp := mfa.TOTP{Digits: 8, Period: 30 * time.Second}
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		at := time.Unix(v.unix, 0)
+		code, err := totp.Code(secret, totp.Step(at))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if code != v.code {
+			t.Errorf("код на %d = %s, ожидалось %s", v.unix, code, v.code)
+		}
+		if step, err := totp.Validate(secret, v.code, at); err != nil || step != totp.Step(at) {
+			t.Errorf("Validate на %d = %d, %v", v.unix, step, err)
+		}
+	}
+}
+
+func TestTOTPCheckParams(t *testing.T) {
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	tests := []struct {
+		name string
+		totp mfa.TOTP
+		ok   bool
+	}{
+		{"по умолчанию", mfa.DefaultTOTP, true},
+		{"8 цифр и шаг 60 секунд", mfa.TOTP{Digits: 8, Period: time.Minute}, true},
+		{"5 цифр", mfa.TOTP{Digits: 5, Period: 30 * time.Second}, false},
+		{"9 цифр", mfa.TOTP{Digits: 9, Period: 30 * time.Second}, false},
+		{"нулевой шаг", mfa.TOTP{Digits: 6}, false},
+		{"шаг меньше секунды", mfa.TOTP{Digits: 6, Period: 500 * time.Millisecond}, false},
+		{"дробный шаг", mfa.TOTP{Digits: 6, Period: 1500 * time.Millisecond}, false},
+		{"отрицательное расхождение", mfa.TOTP{Digits: 6, Period: 30 * time.Second, Skew: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.totp.CheckParams(); (err == nil) != tt.ok {
+				t.Fatalf("CheckParams = %v, ожидалось ok=%v", err, tt.ok)
+			}
+			if _, err := mfa.New(memstore.New(), mfa.WithTOTP(tt.totp)); (err == nil) != tt.ok {
+				t.Errorf("New = %v, ожидалось ok=%v", err, tt.ok)
+			}
+			if !tt.ok {
+				if _, err := tt.totp.Validate(secret, "000000", time.Now()); err == nil {
+					t.Error("Validate с некорректными параметрами не вернул ошибку")
+				}
+			}
+		})
+	}
+}
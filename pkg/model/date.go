@@ -0,0 +1,124 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout — формат календарной даты (full-date из RFC 3339)
+const DateLayout = "2006-01-02"
+
+// Date — календарная дата без времени суток и часового пояса, как колонка типа date.
+// В JSON записывается как "2006-01-02". Реализует sql.Scanner и driver.Valuer,
+// поэтому сканируется из колонки date и передается в запросы без преобразований
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf возвращает календарную дату момента t в его часовом поясе
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// ParseDate разбирает дату в формате "2006-01-02". Для совместимости с прежним API,
+// где даты передавались строками RFC3339, принимает и полную метку времени —
+// время суток при этом отбрасывается
+func ParseDate(s string) (Date, error) {
+	if t, err := time.Parse(DateLayout, s); err == nil {
+		return DateOf(t), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("некорректная дата %q: ожидается формат %s или RFC3339", s, DateLayout)
+	}
+	return DateOf(t), nil
+}
+
+// String возвращает дату в формате "2006-01-02"
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Time возвращает полночь даты в часовом поясе loc. Для записи в базу используется UTC
+func (d Date) Time(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// IsZero сообщает, что дата не задана
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// Before сообщает, что d раньше other
+func (d Date) Before(other Date) bool {
+	return d.Time(time.UTC).Before(other.Time(time.UTC))
+}
+
+// After сообщает, что d позже other
+func (d Date) After(other Date) bool {
+	return d.Time(time.UTC).After(other.Time(time.UTC))
+}
+
+// DaysUntil возвращает количество дней от d до other, например стаж менеджера на дату other
+func (d Date) DaysUntil(other Date) int {
+	return int(other.Time(time.UTC).Sub(d.Time(time.UTC)).Hours() / 24)
+}
+
+// MarshalJSON реализует json.Marshaler. Пустая дата записывается как null
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON реализует json.Unmarshaler. null, как принято в encoding/json, не изменяет дату
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan реализует sql.Scanner
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case string:
+		parsed, err := ParseDate(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	default:
+		return fmt.Errorf("невозможно преобразовать %T в model.Date", src)
+	}
+}
+
+// Value реализует driver.Valuer. Пустая дата записывается как NULL
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.Time(time.UTC), nil
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Date
+		wantErr bool
+	}{
+		{"2024-03-01", Date{2024, time.March, 1}, false},
+		{"2024-02-29", Date{2024, time.February, 29}, false},
+		// Прежний формат RFC3339: время суток отбрасывается, дата берется в часовом поясе метки
+		{"2024-03-01T23:30:00+03:00", Date{2024, time.March, 1}, false},
+		{"2024-03-01T00:00:00Z", Date{2024, time.March, 1}, false},
+		{"2023-02-29", Date{}, true},
+		{"01.03.2024", Date{}, true},
+		{"2024-3-1", Date{}, true},
+		{"", Date{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDate(%q) = %v, %v, ожидалось %v, ошибка=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	type payload struct {
+		HireDate Date  `json:"hire_date"`
+		Optional *Date `json:"optional,omitempty"`
+	}
+
+	data, err := json.Marshal(payload{HireDate: Date{2024, time.March, 1}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `{"hire_date":"2024-03-01"}` {
+		t.Errorf("Marshal = %s", data)
+	}
+	if data, err := json.Marshal(Date{}); err != nil || string(data) != "null" {
+		t.Errorf("Marshal пустой даты = %s, %v, ожидалось null", data, err)
+	}
+
+	var p payload
+	if err := json.Unmarshal([]byte(`{"hire_date":"2024-03-01","optional":"2020-01-31T10:00:00Z"}`), &p); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if p.HireDate != (Date{2024, time.March, 1}) || p.Optional == nil || *p.Optional != (Date{2020, time.January, 31}) {
+		t.Errorf("Unmarshal = %+v", p)
+	}
+
+	p = payload{HireDate: Date{2024, time.March, 1}}
+	if err := json.Unmarshal([]byte(`{"hire_date":null,"optional":null}`), &p); err != nil {
+		t.Fatalf("Unmarshal null: %v", err)
+	}
+	if p.HireDate != (Date{2024, time.March, 1}) || p.Optional != nil {
+		t.Errorf("Unmarshal null = %+v, ожидалось без изменений", p)
+	}
+
+	for _, bad := range []string{`{"hire_date":"01.03.2024"}`, `{"hire_date":20240301}`, `{"hire_date":""}`} {
+		if err := json.Unmarshal([]byte(bad), &p); err == nil {
+			t.Errorf("Unmarshal(%s) принял некорректную дату", bad)
+		}
+	}
+}
+
+func TestDateScanValue(t *testing.T) {
+	want := Date{2024, time.March, 1}
+	tests := []struct {
+		name    string
+		src     any
+		want    Date
+		wantErr bool
+	}{
+		{"time.Time", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), want, false},
+		{"строка", "2024-03-01", want, false},
+		{"NULL", nil, Date{}, false},
+		{"некорректная строка", "yesterday", Date{}, true},
+		{"неподдерживаемый тип", int64(20240301), Date{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Date{1999, time.December, 31}
+			err := d.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) = %v, ожидалась ошибка=%v", tt.src, err, tt.wantErr)
+			}
+			if !tt.wantErr && d != tt.want {
+				t.Errorf("Scan(%v) = %v, ожидалось %v", tt.src, d, tt.want)
+			}
+		})
+	}
+
+	v, err := want.Value()
+	if err != nil || v != time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Value = %v, %v", v, err)
+	}
+	if v, err := (Date{}).Value(); err != nil || v != nil {
+		t.Errorf("Value пустой даты = %v, %v, ожидалось NULL", v, err)
+	}
+}
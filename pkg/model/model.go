@@ -1,43 +1,135 @@
+// Package model содержит сущности CRM.
+//
+// Метки времени хранятся как time.Time, а необязательные (NULL в базе) — как *time.Time,
+// который не попадает в JSON, пока не задан. Дата приема менеджера хранится как Date.
+// Вызывающий код, который раньше работал со строками RFC3339, получает прежнее
+// представление через t.Format(time.RFC3339), а строки разбирает через ParseDate.
+//
+// Теги json задают представление сущностей в API (хеш пароля не сериализуется),
+// теги db — имена колонок для pgx.RowToStructByName
 package model
 
+import "time"
+
 type User struct {
-	ID           string
-	Username     string
-	PasswordHash string
-	Role         string
-	CreatedAt    string
-	UpdatedAt    string
+	ID           string    `json:"id" db:"id"`
+	Username     string    `json:"username" db:"username"`
+	PasswordHash string    `json:"-" db:"password_hash"` // Никогда не сериализуется в JSON
+	Role         string    `json:"role" db:"role"`
+	IsDeleted    bool      `json:"is_deleted" db:"is_deleted"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type Admin struct {
-	ID          string
-	Username    string
-	Permissions map[string]interface{}
-	CreatedAt   string
-	UpdatedAt   string
+	ID          string                 `json:"id" db:"id"`
+	Username    string                 `json:"username" db:"username"`
+	Permissions map[string]interface{} `json:"permissions" db:"permissions"`
+	IsDeleted   bool                   `json:"is_deleted" db:"is_deleted"`
+	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
 }
 
 type Client struct {
-	ID          string
-	Username    string
-	FullName    string
-	PhoneNumber string
-	CreatedAt   string
-	UpdatedAt   string
+	ID          string    `json:"id" db:"id"`
+	Username    string    `json:"username" db:"username"`
+	FullName    string    `json:"full_name" db:"full_name"`
+	PhoneNumber string    `json:"phone_number" db:"phone_number"`
+	IsDeleted   bool      `json:"is_deleted" db:"is_deleted"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type Manager struct {
-	ID        string
-	Username  string
-	FullName  string
-	HireDate  string
-	CreatedAt string
-	UpdatedAt string
+	ID        string    `json:"id" db:"id"`
+	Username  string    `json:"username" db:"username"`
+	FullName  string    `json:"full_name" db:"full_name"`
+	HireDate  Date      `json:"hire_date" db:"hire_date"`
+	IsDeleted bool      `json:"is_deleted" db:"is_deleted"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type UserLog struct {
-	ID        string
-	UserID    string
-	Action    string
-	Timestamp string
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Action    string    `json:"action" db:"action"`
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// UserPatch описывает частичное обновление пользователя. Поля со значением nil не изменяются
+type UserPatch struct {
+	Username *string `json:"username,omitempty"`
+}
+
+// Fields возвращает имена колонок, которые изменяет патч
+func (p UserPatch) Fields() []string {
+	var fields []string
+	if p.Username != nil {
+		fields = append(fields, "username")
+	}
+	return fields
+}
+
+// ClientPatch описывает частичное обновление клиента. Поля со значением nil не изменяются
+type ClientPatch struct {
+	FullName    *string `json:"full_name,omitempty"`
+	PhoneNumber *string `json:"phone_number,omitempty"`
+}
+
+// Fields возвращает имена колонок, которые изменяет патч
+func (p ClientPatch) Fields() []string {
+	var fields []string
+	if p.FullName != nil {
+		fields = append(fields, "full_name")
+	}
+	if p.PhoneNumber != nil {
+		fields = append(fields, "phone_number")
+	}
+	return fields
+}
+
+// ManagerPatch описывает частичное обновление менеджера. Поля со значением nil не изменяются
+type ManagerPatch struct {
+	FullName *string `json:"full_name,omitempty"`
+	HireDate *Date   `json:"hire_date,omitempty"`
+}
+
+// Fields возвращает имена колонок, которые изменяет патч
+func (p ManagerPatch) Fields() []string {
+	var fields []string
+	if p.FullName != nil {
+		fields = append(fields, "full_name")
+	}
+	if p.HireDate != nil {
+		fields = append(fields, "hire_date")
+	}
+	return fields
+}
+
+// MFA — настройки двухфакторной аутентификации пользователя
+type MFA struct {
+	UserID       string     `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`                            // Секрет TOTP в base32, никогда не сериализуется в JSON
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"` // nil — подключение не подтверждено
+	LastUsedStep int64      `json:"-" db:"last_used_step"`                    // Последний принятый шаг TOTP, защита от повторного использования кода
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Confirmed сообщает, подтверждено ли подключение двухфакторной аутентификации
+func (m MFA) Confirmed() bool {
+	return m.ConfirmedAt != nil
+}
+
+// RefreshToken — выданный рефреш токен. Токены, полученные ротацией от одного входа, образуют семейство
+type RefreshToken struct {
+	TokenHash  string     `json:"-" db:"token_hash"` // SHA-256 jti токена
+	FamilyID   string     `json:"family_id" db:"family_id"`
+	ParentHash *string    `json:"-" db:"parent_hash"` // Хеш токена, при ротации которого выдан этот. nil для первого токена семейства
+	UserID     string     `json:"user_id" db:"user_id"`
+	Device     string     `json:"device" db:"device"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty" db:"used_at"`       // nil — токен еще не использован
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"` // nil — семейство не отозвано
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 }
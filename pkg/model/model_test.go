@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionalTimesJSON(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	token := RefreshToken{FamilyID: "family", UserID: "user", ExpiresAt: created.Add(time.Hour), CreatedAt: created}
+	data, err := json.Marshal(token)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if s := string(data); strings.Contains(s, "used_at") || strings.Contains(s, "revoked_at") || strings.Contains(s, "0001-01-01") {
+		t.Errorf("незаданные метки времени попали в JSON: %s", s)
+	}
+
+	used := time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)
+	token.UsedAt = &used
+	data, err = json.Marshal(token)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"used_at":"2026-01-02T04:00:00Z"`) {
+		t.Errorf("used_at не сериализован: %s", data)
+	}
+
+	var mfa MFA
+	if mfa.Confirmed() {
+		t.Error("Confirmed для неподтвержденного подключения")
+	}
+	mfa.ConfirmedAt = &used
+	if !mfa.Confirmed() {
+		t.Error("Confirmed = false для подтвержденного подключения")
+	}
+}
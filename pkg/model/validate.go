@@ -0,0 +1,185 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MinFullNameLength и MaxFullNameLength ограничивают длину ФИО в символах
+	MinFullNameLength = 2
+	MaxFullNameLength = 100
+)
+
+var (
+	// usernamePattern — латинская буква, затем от 2 до 31 латинской буквы, цифры, '_', '.' или '-'
+	usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{2,31}$`)
+	// phonePattern — номер в формате E.164: '+', код страны и до 15 цифр всего
+	phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+)
+
+// FieldError — ошибка проверки одного поля. Field совпадает с именем поля в JSON
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors — список ошибок проверки входных данных по полям
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "некорректные данные: " + strings.Join(parts, "; ")
+}
+
+// add добавляет ошибку поля
+func (e *ValidationErrors) add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// err возвращает nil, если ошибок нет. Нужен, чтобы не вернуть типизированный nil в интерфейсе error
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationErrors) checkUsername(username string) {
+	if !usernamePattern.MatchString(username) {
+		e.add("username", "имя пользователя должно начинаться с латинской буквы и содержать от 3 до 32 латинских букв, цифр, '_', '.' или '-'")
+	}
+}
+
+func (e *ValidationErrors) checkPassword(password string) {
+	if password == "" {
+		e.add("password", "пароль не задан")
+	}
+}
+
+func (e *ValidationErrors) checkFullName(fullName string) {
+	n := utf8.RuneCountInString(strings.TrimSpace(fullName))
+	if n < MinFullNameLength || n > MaxFullNameLength {
+		e.add("full_name", fmt.Sprintf("ФИО должно содержать от %d до %d символов", MinFullNameLength, MaxFullNameLength))
+	}
+}
+
+func (e *ValidationErrors) checkPhone(phone string) {
+	if !phonePattern.MatchString(phone) {
+		e.add("phone_number", "номер телефона должен быть в формате E.164, например +79991234567")
+	}
+}
+
+func (e *ValidationErrors) checkHireDate(hireDate Date) {
+	switch {
+	case hireDate.IsZero():
+		e.add("hire_date", "дата приема на работу не задана")
+	case hireDate.After(DateOf(time.Now())):
+		e.add("hire_date", "дата приема на работу не может быть в будущем")
+	}
+}
+
+func (e *ValidationErrors) checkPermissions(permissions map[string]interface{}) {
+	if permissions == nil {
+		e.add("permissions", "права не заданы, для администратора без прав передайте пустой объект")
+	}
+}
+
+// ValidatePermissions проверяет права администратора: nil не принимается, чтобы в базу не попал JSON null.
+// Возвращает ValidationErrors
+func ValidatePermissions(permissions map[string]interface{}) error {
+	var errs ValidationErrors
+	errs.checkPermissions(permissions)
+	return errs.err()
+}
+
+// CreateAdminInput — данные для создания администратора
+type CreateAdminInput struct {
+	Username    string                 `json:"username"`
+	Password    string                 `json:"password"`
+	Permissions map[string]interface{} `json:"permissions"`
+}
+
+// Validate проверяет входные данные и возвращает ValidationErrors со всеми ошибками полей
+func (in CreateAdminInput) Validate() error {
+	var errs ValidationErrors
+	errs.checkUsername(in.Username)
+	errs.checkPassword(in.Password)
+	errs.checkPermissions(in.Permissions)
+	return errs.err()
+}
+
+// CreateClientInput — данные для создания клиента
+type CreateClientInput struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	FullName    string `json:"full_name"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+// Validate проверяет входные данные и возвращает ValidationErrors со всеми ошибками полей
+func (in CreateClientInput) Validate() error {
+	var errs ValidationErrors
+	errs.checkUsername(in.Username)
+	errs.checkPassword(in.Password)
+	errs.checkFullName(in.FullName)
+	errs.checkPhone(in.PhoneNumber)
+	return errs.err()
+}
+
+// CreateManagerInput — данные для создания менеджера
+type CreateManagerInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	FullName string `json:"full_name"`
+	HireDate Date   `json:"hire_date"`
+}
+
+// Validate проверяет входные данные и возвращает ValidationErrors со всеми ошибками полей
+func (in CreateManagerInput) Validate() error {
+	var errs ValidationErrors
+	errs.checkUsername(in.Username)
+	errs.checkPassword(in.Password)
+	errs.checkFullName(in.FullName)
+	errs.checkHireDate(in.HireDate)
+	return errs.err()
+}
+
+// Validate проверяет заданные поля патча
+func (p UserPatch) Validate() error {
+	var errs ValidationErrors
+	if p.Username != nil {
+		errs.checkUsername(*p.Username)
+	}
+	return errs.err()
+}
+
+// Validate проверяет заданные поля патча
+func (p ClientPatch) Validate() error {
+	var errs ValidationErrors
+	if p.FullName != nil {
+		errs.checkFullName(*p.FullName)
+	}
+	if p.PhoneNumber != nil {
+		errs.checkPhone(*p.PhoneNumber)
+	}
+	return errs.err()
+}
+
+// Validate проверяет заданные поля патча
+func (p ManagerPatch) Validate() error {
+	var errs ValidationErrors
+	if p.FullName != nil {
+		errs.checkFullName(*p.FullName)
+	}
+	if p.HireDate != nil {
+		errs.checkHireDate(*p.HireDate)
+	}
+	return errs.err()
+}
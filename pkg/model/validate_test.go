@@ -0,0 +1,93 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fields возвращает отсортированные имена полей из ValidationErrors
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("ожидалась ValidationErrors, получено %T: %v", err, err)
+	}
+	names := make([]string, len(verrs))
+	for i, fe := range verrs {
+		names[i] = fe.Field
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestValidate(t *testing.T) {
+	hireDate := Date{2024, time.March, 1}
+	future := DateOf(time.Now().AddDate(0, 0, 2))
+	name, short, badPhone := "Иван Иванов", "И", "89991234567"
+
+	tests := []struct {
+		name  string
+		input interface{ Validate() error }
+		want  []string
+	}{
+		{"администратор", CreateAdminInput{Username: "admin", Password: "secret", Permissions: map[string]interface{}{}}, nil},
+		{"администратор без прав и пароля", CreateAdminInput{Username: "1admin"}, []string{"password", "permissions", "username"}},
+		{"клиент", CreateClientInput{Username: "client", Password: "secret", FullName: name, PhoneNumber: "+79991234567"}, nil},
+		{"клиент с ошибками", CreateClientInput{Username: "cl", Password: "secret", FullName: " ", PhoneNumber: badPhone},
+			[]string{"full_name", "phone_number", "username"}},
+		{"менеджер", CreateManagerInput{Username: "manager", Password: "secret", FullName: name, HireDate: hireDate}, nil},
+		{"менеджер без даты", CreateManagerInput{Username: "manager", Password: "secret", FullName: name}, []string{"hire_date"}},
+		{"менеджер из будущего", CreateManagerInput{Username: "manager", Password: "secret", FullName: name, HireDate: future}, []string{"hire_date"}},
+		{"пустой патч пользователя", UserPatch{}, nil},
+		{"патч пользователя", UserPatch{Username: &badPhone}, []string{"username"}},
+		{"патч клиента", ClientPatch{FullName: &short, PhoneNumber: &badPhone}, []string{"full_name", "phone_number"}},
+		{"патч менеджера", ManagerPatch{FullName: &name, HireDate: &future}, []string{"hire_date"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fields(t, tt.input.Validate()); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ошибки в полях %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsJSON(t *testing.T) {
+	err := CreateClientInput{Username: "client", Password: "secret", FullName: "Иван Иванов", PhoneNumber: "123"}.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 {
+		t.Fatalf("Validate = %v", err)
+	}
+	data, jerr := json.Marshal(verrs)
+	if jerr != nil {
+		t.Fatalf("Marshal: %v", jerr)
+	}
+	if !strings.HasPrefix(string(data), `[{"field":"phone_number","message":`) {
+		t.Errorf("Marshal = %s", data)
+	}
+}
+
+func TestUserJSONHidesPasswordHash(t *testing.T) {
+	u := User{ID: "id", Username: "client", PasswordHash: "$argon2id$v=19$secret-hash", Role: "client"}
+	data, err := json.Marshal(u)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "secret-hash") || strings.Contains(string(data), "password") {
+		t.Errorf("хеш пароля попал в JSON: %s", data)
+	}
+	mfa, err := json.Marshal(MFA{UserID: "id", Secret: "JBSWY3DPEHPK3PXP"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(mfa), "JBSWY3DPEHPK3PXP") {
+		t.Errorf("секрет TOTP попал в JSON: %s", mfa)
+	}
+}
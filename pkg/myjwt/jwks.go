@@ -0,0 +1,174 @@
+package myjwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// JWK — открытый ключ в формате JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`           // RSA, EC или OKP
+	Kid string `json:"kid,omitempty"` // Идентификатор ключа, совпадает с заголовком kid токенов
+	Use string `json:"use,omitempty"` // sig
+	Alg string `json:"alg,omitempty"` // RS256, ES256, EdDSA, ...
+	N   string `json:"n,omitempty"`   // Модуль RSA
+	E   string `json:"e,omitempty"`   // Экспонента RSA
+	Crv string `json:"crv,omitempty"` // Кривая EC или OKP
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS — набор открытых ключей (RFC 7517, раздел 5)
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK возвращает открытую часть ключа. Для ключа HMAC возвращается ошибка: общий секрет не публикуется
+func (k *Key) PublicJWK() (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, fmt.Errorf("ключ %q нельзя опубликовать в JWKS", k.ID)
+	}
+	return jwk, nil
+}
+
+// PublicJWKS возвращает открытые ключи всех заданных асимметричных ключей, включая выведенные из ротации
+// в течение льготного периода, отсортированные по kid.
+// Ключи HMAC в набор не попадают
+func PublicJWKS() (JWKS, error) {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range publicKeys() {
+		jwk, err := k.PublicJWK()
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set, nil
+}
+
+// JWKSDocument возвращает JSON-документ JWKS для публикации, например по адресу /.well-known/jwks.json.
+// Сервисы, которые только проверяют токены, загружают его через ParseJWKS и SetKeys(nil, keys...)
+// или через KeyRing с источником, который скачивает документ
+func JWKSDocument() ([]byte, error) {
+	set, err := PublicJWKS()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(set)
+}
+
+// ParseJWKS разбирает JSON-документ JWKS в ключи только для проверки подписи
+func ParseJWKS(data []byte) ([]*Key, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("некорректный документ JWKS: %w", err)
+	}
+	keys := make([]*Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", jwk.Kid, err)
+		}
+		k, err := NewPublicKey(jwk.Kid, key)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Alg != "" && jwk.Alg != k.Method.Alg() {
+			return nil, fmt.Errorf("ключ %q: алгоритм %s не соответствует типу ключа", jwk.Kid, jwk.Alg)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// publicKey восстанавливает открытый ключ из JWK
+func (jwk JWK) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("некорректная экспонента RSA")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("точка не лежит на кривой %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("некорректная длина ключа Ed25519")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("неподдерживаемый тип ключа %q", jwk.Kty)
+}
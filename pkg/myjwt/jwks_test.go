@@ -0,0 +1,80 @@
+package myjwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+)
+
+func TestPublicJWKRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	var keys []*Key
+	for kid, signer := range map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey} {
+		key, err := NewPrivateKey(kid, signer)
+		if err != nil {
+			t.Fatalf("NewPrivateKey: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	keys = append(keys, newEd25519Key(t, "ed"))
+
+	set := JWKS{}
+	for _, k := range keys {
+		jwk, err := k.PublicJWK()
+		if err != nil {
+			t.Fatalf("PublicJWK %q: %v", k.ID, err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	parsed, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	if len(parsed) != len(keys) {
+		t.Fatalf("ParseJWKS вернул %d ключей, ожидалось %d", len(parsed), len(keys))
+	}
+	for i, k := range keys {
+		got := parsed[i]
+		if got.ID != k.ID || got.Method != k.Method || got.CanSign() {
+			t.Errorf("ключ %q, %s, CanSign=%v, ожидалось %q, %s, только проверка",
+				got.ID, got.Method.Alg(), got.CanSign(), k.ID, k.Method.Alg())
+		}
+		if !k.verifyKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(got.verifyKey) {
+			t.Errorf("ключ %q: открытый ключ изменился после ParseJWKS", k.ID)
+		}
+	}
+
+	if _, err := NewHMACKey("hs", []byte("secret")).PublicJWK(); err == nil {
+		t.Error("PublicJWK опубликовал ключ HMAC")
+	}
+}
+
+func TestParseJWKSRejectsAlgMismatch(t *testing.T) {
+	jwk, err := newEd25519Key(t, "ed").PublicJWK()
+	if err != nil {
+		t.Fatalf("PublicJWK: %v", err)
+	}
+	jwk.Alg = "RS256"
+	data, err := json.Marshal(JWKS{Keys: []JWK{jwk}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if _, err := ParseJWKS(data); err == nil {
+		t.Error("ParseJWKS принял ключ Ed25519 с алгоритмом RS256")
+	}
+}
@@ -1,85 +1,209 @@
 package myjwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"github.com/Maden-in-haven/crmlib/pkg/config"
-	"github.com/golang-jwt/jwt/v5"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Типы токенов, записываемые в claim typ
+const (
+	TypeAccess  = "access"  // Основной токен
+	TypeRefresh = "refresh" // Рефреш токен
+)
+
+// Время жизни токенов
+const (
+	AccessTokenTTL  = 12 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrUnexpectedType возвращается ValidateJWT, если typ токена не совпадает с ожидаемым
+	ErrUnexpectedType = errors.New("неожиданный тип токена")
+	// ErrTokenRevoked возвращается ValidateJWT для токена, отозванного до истечения срока
+	ErrTokenRevoked = errors.New("токен отозван")
+)
+
+// RevocationChecker проверяет, не отозван ли токен до истечения срока
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+}
+
+var (
+	revocationMu      sync.RWMutex
+	revocationChecker RevocationChecker
 )
 
-// GenerateJWT генерирует JWT токен для указанного пользователя
-func GenerateJWT(userID string) (string, error) {
-	// Определяем время истечения токена (например, 12 часов)
-	tokenExpirationTime := time.Now().Add(12 * time.Hour)
-
-	// Создаем claims с добавлением читаемого времени и типа токена
-	claims := jwt.MapClaims{
-		"sub":          userID,                                   // ID пользователя
-		"exp":          tokenExpirationTime.Unix(),               // Время истечения в Unix формате (обязательно для JWT)
-		"iat":          time.Now().Unix(),                        // Время создания в Unix формате
-		"typ":          "access",                                 // Тип токена: access (основной токен)
-		"exp_readable": tokenExpirationTime.Format(time.RFC3339), // Читаемое время истечения (ISO 8601)
-		"iat_readable": time.Now().Format(time.RFC3339),          // Читаемое время создания (ISO 8601)
+// SetRevocationChecker задает проверку отзыва, которую выполняет ValidateJWT для каждого токена.
+// nil отключает проверку
+func SetRevocationChecker(checker RevocationChecker) {
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+	revocationChecker = checker
+}
+
+// Claims — содержимое токена. Стандартные поля (sub, iss, aud, exp, iat, jti) задаются через RegisteredClaims
+type Claims struct {
+	jwt.RegisteredClaims
+	Type   string   `json:"typ"`              // Тип токена: TypeAccess или TypeRefresh
+	Role   string   `json:"role,omitempty"`   // Роль пользователя, чтобы сервисам не запрашивать ее из базы
+	Scopes []string `json:"scopes,omitempty"` // Права администратора, см. Scopes
+}
+
+// Scopes возвращает права администратора в виде отсортированного списка:
+// в токен попадают ключи permissions со значением true
+func Scopes(permissions map[string]interface{}) []string {
+	var scopes []string
+	for name, value := range permissions {
+		if allowed, ok := value.(bool); ok && allowed {
+			scopes = append(scopes, name)
+		}
 	}
-	fmt.Println(claims)
-	// Создаем новый токен с алгоритмом подписи и claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	sort.Strings(scopes)
+	return scopes
+}
+
+// HasScope сообщает, есть ли в токене право scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
 
-	// Подписываем токен с помощью секретного ключа
-	tokenString, err := token.SignedString([]byte(config.LoadJWTConfig().SecretKey))
+// NewClaims создает claims токена типа typ для пользователя userID со сроком действия ttl
+// и случайным идентификатором jti
+func NewClaims(typ, userID string, ttl time.Duration) (*Claims, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации идентификатора токена: %w", err)
+	}
+	cfg := currentConfig()
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   userID,                           // ID пользователя
+			Issuer:    cfg.Issuer,                       // Кто выдал токен
+			Audience:  jwt.ClaimStrings{cfg.Audience},   // Для кого предназначен токен
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)), // Время истечения
+			IssuedAt:  jwt.NewNumericDate(now),          // Время создания
+		},
+		Type: typ,
+	}, nil
+}
+
+// Sign подписывает claims активным ключом подписи (SetKeys, SetKeyRing) или, если ключи не заданы, секретным ключом из конфигурации
+func Sign(claims *Claims) (string, error) {
+	key, err := currentSigningKey()
 	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	// Создаем новый токен с алгоритмом подписи и claims
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	// Подписываем токен
+	return token.SignedString(key.signKey)
 }
 
-// GenerateRefreshToken генерирует рефреш токен для указанного пользователя
-func GenerateRefreshToken(userID string) (string, error) {
-	// Определяем время истечения рефреш токена (например, 7 дней)
-	tokenExpirationTime := time.Now().Add(7 * 24 * time.Hour)
-
-	// Создаем claims для рефреш токена
-	claims := jwt.MapClaims{
-		"sub":          userID,                                   // ID пользователя
-		"exp":          tokenExpirationTime.Unix(),               // Время истечения в Unix формате
-		"iat":          time.Now().Unix(),                        // Время создания в Unix формате
-		"typ":          "refresh",                                // Указываем тип токена как "refresh"
-		"exp_readable": tokenExpirationTime.Format(time.RFC3339), // Читаемое время истечения
-		"iat_readable": time.Now().Format(time.RFC3339),          // Читаемое время создания
+// GenerateJWT генерирует JWT токен для указанного пользователя с его ролью и правами
+func GenerateJWT(userID, role string, scopes []string) (string, error) {
+	claims, err := NewClaims(TypeAccess, userID, AccessTokenTTL)
+	if err != nil {
+		return "", err
 	}
+	claims.Role = role
+	claims.Scopes = scopes
+	return Sign(claims)
+}
 
-	// Создаем новый рефреш токен с алгоритмом подписи и claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Подписываем рефреш токен с помощью секретного ключа
-	tokenString, err := token.SignedString([]byte(config.LoadJWTConfig().SecretKey))
+// GenerateRefreshToken генерирует рефреш токен для указанного пользователя.
+//
+// Deprecated: токен не сохраняется в базе и не принимается при ротации,
+// используйте user.Sessions.StartSession.
+func GenerateRefreshToken(userID string) (string, error) {
+	claims, err := NewClaims(TypeRefresh, userID, RefreshTokenTTL)
 	if err != nil {
 		return "", err
 	}
+	return Sign(claims)
+}
 
-	return tokenString, nil
+// ValidateJWT проверяет подпись ключом, выбранным по заголовку kid, срок действия, издателя и получателя токена и то,
+// что его тип равен expectedType (TypeAccess или TypeRefresh).
+// Если задан SetRevocationChecker, для отозванного токена возвращается ErrTokenRevoked
+func ValidateJWT(tokenString, expectedType string) (*Claims, error) {
+	return ValidateJWTContext(context.Background(), tokenString, expectedType)
 }
 
-// Валидация JWT с использованием конфигурации
-func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
+// ValidateJWTContext — ValidateJWT с контекстом для проверки отзыва
+func ValidateJWTContext(ctx context.Context, tokenString, expectedType string) (*Claims, error) {
+	cfg := currentConfig()
+
 	// Парсим и валидируем токен
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Ключ выбирается по kid, алгоритм токена должен совпадать с алгоритмом ключа
+		kid, _ := token.Header["kid"].(string)
+		key, err := lookupKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, errors.New("неожиданный метод подписи")
 		}
-		return []byte(config.LoadJWTConfig().SecretKey), nil
-	})
-
+		return key.verifyKey, nil
+	},
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	// Возвращаем claims, если токен валиден
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	if claims.Type != expectedType {
+		return nil, fmt.Errorf("%w: ожидался %q, получен %q", ErrUnexpectedType, expectedType, claims.Type)
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, errors.New("недействительный токен")
+	}
+
+	revocationMu.RLock()
+	checker := revocationChecker
+	revocationMu.RUnlock()
+	if checker != nil {
+		revoked, err := checker.IsRevoked(ctx, claims)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка проверки отзыва токена: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
 	}
+	return claims, nil
+}
 
-	return nil, errors.New("недействительный токен")
+// newTokenID генерирует случайный идентификатор токена (jti)
+func newTokenID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
 }
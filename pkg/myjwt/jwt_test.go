@@ -0,0 +1,131 @@
+package myjwt
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGenerateAndValidate(t *testing.T) {
+	access, err := GenerateJWT("user-1", "admin", []string{"reports", "users"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	claims, err := ValidateJWT(access, TypeAccess)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Role != "admin" || claims.ID == "" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if !claims.HasScope("users") || claims.HasScope("settings") {
+		t.Errorf("Scopes = %v", claims.Scopes)
+	}
+
+	refresh, err := GenerateRefreshToken("user-1")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if _, err := ValidateJWT(refresh, TypeAccess); !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("рефреш токен как токен доступа: ожидалась ErrUnexpectedType, получено %v", err)
+	}
+	if _, err := ValidateJWT(access, TypeRefresh); !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("токен доступа как рефреш токен: ожидалась ErrUnexpectedType, получено %v", err)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Claims)
+	}{
+		{"истекший", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }},
+		{"без срока действия", func(c *Claims) { c.ExpiresAt = nil }},
+		{"чужой издатель", func(c *Claims) { c.Issuer = "other" }},
+		{"чужой получатель", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }},
+		{"выдан в будущем", func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour)) }},
+		{"без jti", func(c *Claims) { c.ID = "" }},
+		{"без пользователя", func(c *Claims) { c.Subject = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := NewClaims(TypeAccess, "user-1", time.Hour)
+			if err != nil {
+				t.Fatalf("NewClaims: %v", err)
+			}
+			tt.modify(claims)
+			token, err := Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if _, err := ValidateJWT(token, TypeAccess); err == nil {
+				t.Error("токен принят")
+			}
+		})
+	}
+}
+
+func TestScopes(t *testing.T) {
+	got := Scopes(map[string]interface{}{"users": true, "reports": false, "settings": "true", "audit": true})
+	if len(got) != 2 || got[0] != "audit" || got[1] != "users" {
+		t.Errorf("Scopes = %v, ожидалось [audit users]", got)
+	}
+}
+
+func TestConfigLoadedOnce(t *testing.T) {
+	t.Setenv("JWT_ISSUER", "first")
+	if err := SetKeys(nil); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	t.Cleanup(func() { _ = SetKeys(nil) })
+
+	claims, err := NewClaims(TypeAccess, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("NewClaims: %v", err)
+	}
+	token, err := Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// Конфигурация не перечитывается для каждого токена
+	t.Setenv("JWT_ISSUER", "second")
+	got, err := NewClaims(TypeAccess, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("NewClaims: %v", err)
+	}
+	if got.Issuer != "first" {
+		t.Errorf("Issuer = %q, ожидался first", got.Issuer)
+	}
+	if _, err := ValidateJWT(token, TypeAccess); err != nil {
+		t.Errorf("ValidateJWT: %v", err)
+	}
+
+	// SetKeys перечитывает конфигурацию
+	if err := SetKeys(nil); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	if _, err := ValidateJWT(token, TypeAccess); err == nil {
+		t.Error("токен чужого издателя принят после перечитывания конфигурации")
+	}
+}
+
+func TestTimeClaimsWholeSeconds(t *testing.T) {
+	token, err := GenerateJWT("user-1", "client", nil)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	for _, name := range []string{"iat", "exp"} {
+		value, ok := claims[name].(float64)
+		if !ok || value != math.Trunc(value) {
+			t.Errorf("%s = %v, ожидалось целое число секунд", name, claims[name])
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package myjwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/config"
+)
+
+// DefaultKeyGracePeriod — сколько ключ, удаленный из источника, еще принимается при проверке токенов.
+// Равен сроку жизни рефреш токена, чтобы ротация ключа не завершала сеансы
+const DefaultKeyGracePeriod = RefreshTokenTTL
+
+// KeySource загружает ключи кольца: активный ключ подписи (nil, если сервис только проверяет токены)
+// и ключи проверки, выбираемые по kid
+type KeySource func() (signing *Key, verification []*Key, err error)
+
+// StaticKeys возвращает источник с неизменным набором ключей
+func StaticKeys(signing *Key, verification ...*Key) KeySource {
+	return func() (*Key, []*Key, error) {
+		return signing, verification, nil
+	}
+}
+
+// DirKeySource загружает ключи из каталога: kid — имя файла без расширения.
+// Файлы *.pem разбираются ParseKeyPEM, файлы *.secret содержат общий секрет HS256 (см. config.CheckSecret),
+// остальные файлы пропускаются.
+// activeKID — kid ключа подписи, пустой activeKID — только проверка
+func DirKeySource(dir, activeKID string) KeySource {
+	return func() (*Key, []*Key, error) {
+		files, err := dirKeyFiles(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return loadKeyFiles(files, activeKID)
+	}
+}
+
+// FileKeySource загружает ключи из файлов, заданных по kid, см. DirKeySource
+func FileKeySource(files map[string]string, activeKID string) KeySource {
+	return func() (*Key, []*Key, error) {
+		return loadKeyFiles(files, activeKID)
+	}
+}
+
+// ConfigKeySource загружает ключи из каталога cfg.Dir и файлов cfg.Files, см. config.LoadKeyRingConfig
+func ConfigKeySource(cfg *config.KeyRingConfig) KeySource {
+	return func() (*Key, []*Key, error) {
+		files := make(map[string]string, len(cfg.Files))
+		if cfg.Dir != "" {
+			dirFiles, err := dirKeyFiles(cfg.Dir)
+			if err != nil {
+				return nil, nil, err
+			}
+			for kid, path := range dirFiles {
+				files[kid] = path
+			}
+		}
+		for kid, path := range cfg.Files {
+			if existing, ok := files[kid]; ok && existing != path {
+				return nil, nil, fmt.Errorf("ключ %q задан дважды: %s и %s", kid, existing, path)
+			}
+			files[kid] = path
+		}
+		if len(files) == 0 {
+			return nil, nil, errors.New("ключи JWT не заданы: укажите JWT_KEY_DIR или JWT_KEYS")
+		}
+		return loadKeyFiles(files, cfg.ActiveKeyID)
+	}
+}
+
+// dirKeyFiles возвращает файлы ключей каталога по kid
+func dirKeyFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога ключей: %w", err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".pem" && ext != ".secret") {
+			continue
+		}
+		kid := strings.TrimSuffix(entry.Name(), ext)
+		if _, ok := files[kid]; ok {
+			return nil, fmt.Errorf("ключ %q задан дважды в каталоге %s", kid, dir)
+		}
+		files[kid] = filepath.Join(dir, entry.Name())
+	}
+	return files, nil
+}
+
+// loadKeyFiles загружает ключи из файлов и выбирает ключ подписи по activeKID
+func loadKeyFiles(files map[string]string, activeKID string) (*Key, []*Key, error) {
+	var signing *Key
+	keys := make([]*Key, 0, len(files))
+	for kid, path := range files {
+		key, err := loadKeyFile(kid, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kid == activeKID {
+			signing = key
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	if activeKID != "" && signing == nil {
+		return nil, nil, fmt.Errorf("%w: активный ключ %q не найден", ErrUnknownKey, activeKID)
+	}
+	return signing, keys, nil
+}
+
+// loadKeyFile загружает ключ из файла *.pem или *.secret
+func loadKeyFile(kid, path string) (*Key, error) {
+	if filepath.Ext(path) != ".secret" {
+		return LoadKeyFile(kid, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ключа %q: %w", kid, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if err := config.CheckSecret(secret); err != nil {
+		return nil, fmt.Errorf("ключ %q: %w", kid, err)
+	}
+	return NewHMACKey(kid, []byte(secret)), nil
+}
+
+// KeyRingOption настраивает KeyRing
+type KeyRingOption func(*KeyRing)
+
+// WithGracePeriod задает, сколько ключ, удаленный из источника, еще принимается при проверке токенов
+func WithGracePeriod(d time.Duration) KeyRingOption {
+	return func(r *KeyRing) {
+		r.grace = d
+	}
+}
+
+type retiredKey struct {
+	key   *Key
+	until time.Time // Время, после которого ключ больше не принимается
+}
+
+// KeyRing — кольцо ключей: активный ключ подписи и ключи проверки, выбираемые по kid.
+// Ключи перечитываются из источника через Reload или Watch. Ключ, пропавший из источника,
+// еще DefaultKeyGracePeriod (WithGracePeriod) принимается при проверке, поэтому ротация выглядит так:
+// добавить новый ключ, сделать его активным, удалить старый
+type KeyRing struct {
+	source KeySource
+	grace  time.Duration
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key       // Ключи из источника по kid
+	retired map[string]retiredKey // Удаленные из источника ключи по kid
+}
+
+// NewKeyRing создает кольцо ключей и загружает ключи из источника
+func NewKeyRing(source KeySource, opts ...KeyRingOption) (*KeyRing, error) {
+	r := &KeyRing{
+		source:  source,
+		grace:   DefaultKeyGracePeriod,
+		now:     time.Now,
+		keys:    make(map[string]*Key),
+		retired: make(map[string]retiredKey),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload перечитывает ключи из источника. При ошибке остаются прежние ключи
+func (r *KeyRing) Reload() error {
+	signing, verification, err := r.source()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки ключей JWT: %w", err)
+	}
+	if signing != nil && !signing.CanSign() {
+		return fmt.Errorf("ключ %q не содержит закрытой части", signing.ID)
+	}
+	keys := make(map[string]*Key, len(verification)+1)
+	for _, k := range append([]*Key{signing}, verification...) {
+		if k == nil {
+			continue
+		}
+		if existing, ok := keys[k.ID]; ok && existing != k {
+			return fmt.Errorf("ключ %q задан дважды", k.ID)
+		}
+		keys[k.ID] = k
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for kid, k := range r.keys {
+		if _, ok := keys[kid]; !ok {
+			r.retired[kid] = retiredKey{key: k, until: now.Add(r.grace)}
+		}
+	}
+	for kid, entry := range r.retired {
+		// Вернувшийся в источник ключ снова действует без ограничения срока
+		if _, ok := keys[kid]; ok || !now.Before(entry.until) {
+			delete(r.retired, kid)
+		}
+	}
+	r.signing, r.keys = signing, keys
+	return nil
+}
+
+// retire добавляет ключ проверки, который принимается еще в течение льготного периода,
+// если в кольце нет ключа с тем же kid
+func (r *KeyRing) retire(k *Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[k.ID]; ok {
+		return
+	}
+	r.retired[k.ID] = retiredKey{key: k, until: r.now().Add(r.grace)}
+}
+
+// Watch перечитывает ключи каждые interval, пока не отменен ctx. Ошибки загрузки пишутся в лог.
+// При interval <= 0 ключи не перечитываются
+func (r *KeyRing) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("%v, используются прежние ключи", err)
+			}
+		}
+	}
+}
+
+// Signing возвращает активный ключ подписи
+func (r *KeyRing) Signing() (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.signing == nil {
+		return nil, errors.New("ключ подписи не задан, сервис только проверяет токены")
+	}
+	return r.signing, nil
+}
+
+// Lookup возвращает ключ проверки по kid, в том числе удаленный из источника ключ в течение льготного периода
+func (r *KeyRing) Lookup(kid string) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if k, ok := r.keys[kid]; ok {
+		return k, nil
+	}
+	if entry, ok := r.retired[kid]; ok && r.now().Before(entry.until) {
+		return entry.key, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// Keys возвращает все ключи проверки, отсортированные по kid
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := r.now()
+	keys := make([]*Key, 0, len(r.keys)+len(r.retired))
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	for _, entry := range r.retired {
+		if now.Before(entry.until) {
+			keys = append(keys, entry.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// LoadKeyRing создает кольцо ключей по конфигурации из переменных окружения (config.LoadKeyRingConfig)
+// и задает его через SetKeyRing. Ключи перечитываются каждые cfg.ReloadInterval, пока не отменен ctx
+func LoadKeyRing(ctx context.Context) (*KeyRing, error) {
+	cfg := config.LoadKeyRingConfig()
+	ring, err := NewKeyRing(ConfigKeySource(cfg), WithGracePeriod(cfg.GracePeriod))
+	if err != nil {
+		return nil, err
+	}
+	SetKeyRing(ring)
+	if cfg.ReloadInterval > 0 {
+		go ring.Watch(ctx, cfg.ReloadInterval)
+	}
+	return ring, nil
+}
@@ -0,0 +1,136 @@
+package myjwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newEd25519Key создает ключ подписи EdDSA с идентификатором kid
+func newEd25519Key(t *testing.T, kid string) *Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key, err := NewPrivateKey(kid, priv)
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	return key
+}
+
+func TestLegacySecretAfterSetKeys(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "legacy-secret-key-0123456789abcdef")
+	if err := SetKeys(nil); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	t.Cleanup(func() { _ = SetKeys(nil) })
+
+	legacy, err := GenerateJWT("user-1", "client", nil)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	if err := SetKeys(newEd25519Key(t, "ed-1")); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	if _, err := ValidateJWT(legacy, TypeAccess); err != nil {
+		t.Errorf("токен, подписанный секретом до перехода на ключи: %v", err)
+	}
+	set, err := PublicJWKS()
+	if err != nil {
+		t.Fatalf("PublicJWKS: %v", err)
+	}
+	for _, k := range set.Keys {
+		if k.Kid == "" {
+			t.Errorf("секрет опубликован в JWKS: %+v", k)
+		}
+	}
+
+	ring := currentKeyRing()
+	ring.now = func() time.Time { return time.Now().Add(DefaultKeyGracePeriod + time.Minute) }
+	if _, err := ValidateJWT(legacy, TypeAccess); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("после льготного периода: ожидалась ErrUnknownKey, получено %v", err)
+	}
+}
+
+func TestWatchNonPositiveInterval(t *testing.T) {
+	ring, err := NewKeyRing(StaticKeys(newEd25519Key(t, "ed-1")))
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		ring.Watch(context.Background(), 0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch с нулевым интервалом не завершился")
+	}
+}
+
+func TestKeyRingReloadGracePeriod(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t, "old"), newEd25519Key(t, "new")
+	signing := oldKey
+	source := func() (*Key, []*Key, error) { return signing, nil, nil }
+
+	now := time.Now()
+	ring, err := NewKeyRing(source, WithGracePeriod(time.Hour))
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	ring.now = func() time.Time { return now }
+
+	// Ротация: новый ключ подписи, старый пропадает из источника
+	signing = newKey
+	if err := ring.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if k, err := ring.Signing(); err != nil || k != newKey {
+		t.Errorf("Signing = %v, %v, ожидался новый ключ", k, err)
+	}
+	if k, err := ring.Lookup("old"); err != nil || k != oldKey {
+		t.Errorf("Lookup старого ключа в льготный период = %v, %v", k, err)
+	}
+	if n := len(ring.Keys()); n != 2 {
+		t.Errorf("Keys в льготный период: %d ключей, ожидалось 2", n)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := ring.Lookup("old"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Lookup старого ключа после льготного периода: ожидалась ErrUnknownKey, получено %v", err)
+	}
+	if n := len(ring.Keys()); n != 1 {
+		t.Errorf("Keys после льготного периода: %d ключей, ожидался 1", n)
+	}
+
+	// Ошибка источника оставляет прежние ключи
+	failing := errors.New("источник недоступен")
+	ring.source = func() (*Key, []*Key, error) { return nil, nil, failing }
+	if err := ring.Reload(); !errors.Is(err, failing) {
+		t.Errorf("Reload = %v, ожидалась ошибка источника", err)
+	}
+	if k, err := ring.Lookup("new"); err != nil || k != newKey {
+		t.Errorf("Lookup после неудачного Reload = %v, %v", k, err)
+	}
+
+	// Вернувшийся в источник ключ снова действует без ограничения срока
+	ring.source = source
+	signing = oldKey
+	if err := ring.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := ring.Lookup("old"); err != nil {
+		t.Errorf("Lookup вернувшегося ключа: %v", err)
+	}
+	if _, err := ring.Lookup("new"); err == nil {
+		t.Error("ключ new принимается после окончания льготного периода")
+	}
+}
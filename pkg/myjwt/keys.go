@@ -0,0 +1,285 @@
+package myjwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Maden-in-haven/crmlib/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownKey возвращается ValidateJWT, если ключ из заголовка kid не настроен
+var ErrUnknownKey = errors.New("неизвестный ключ подписи")
+
+// Key — ключ подписи или проверки токенов с идентификатором kid.
+// Для асимметричных алгоритмов ключ, загруженный из открытого ключа, только проверяет подпись
+type Key struct {
+	ID     string            // Значение заголовка kid. Пустой ID — токены без kid
+	Method jwt.SigningMethod // Алгоритм подписи
+
+	signKey   interface{} // nil — ключ только для проверки
+	verifyKey interface{}
+}
+
+// CanSign сообщает, можно ли подписывать токены этим ключом
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// NewHMACKey создает ключ HS256 с общим секретом
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewPrivateKey создает ключ подписи из закрытого ключа RSA (RS256), ECDSA (ES256, ES384, ES512 по кривой)
+// или Ed25519 (EdDSA)
+func NewPrivateKey(kid string, key crypto.Signer) (*Key, error) {
+	k, err := NewPublicKey(kid, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	k.signKey = key
+	return k, nil
+}
+
+// NewPublicKey создает ключ только для проверки подписи из открытого ключа RSA, ECDSA или Ed25519
+func NewPublicKey(kid string, key crypto.PublicKey) (*Key, error) {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("ключ RSA %q короче 2048 бит", kid)
+		}
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, verifyKey: pub}, nil
+	case *ecdsa.PublicKey:
+		method, err := ecdsaMethod(pub.Curve)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", kid, err)
+		}
+		return &Key{ID: kid, Method: method, verifyKey: pub}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, verifyKey: pub}, nil
+	}
+	return nil, fmt.Errorf("ключ %q: неподдерживаемый тип ключа %T", kid, key)
+}
+
+func ecdsaMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	}
+	return nil, fmt.Errorf("неподдерживаемая кривая %s", curve.Params().Name)
+}
+
+// ParseKeyPEM создает ключ из PEM: закрытого (PKCS #8, PKCS #1, SEC 1) или открытого (PKIX, PKCS #1).
+// Алгоритм определяется по типу ключа
+func ParseKeyPEM(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("ключ %q: PEM-блок не найден", kid)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", kid, err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("ключ %q: неподдерживаемый тип ключа %T", kid, key)
+		}
+		return NewPrivateKey(kid, signer)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", kid, err)
+		}
+		return NewPrivateKey(kid, key)
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", kid, err)
+		}
+		return NewPrivateKey(kid, key)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", kid, err)
+		}
+		return NewPublicKey(kid, key)
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", kid, err)
+		}
+		return NewPublicKey(kid, key)
+	}
+	return nil, fmt.Errorf("ключ %q: неподдерживаемый тип PEM-блока %q", kid, block.Type)
+}
+
+// LoadKeyFile загружает ключ из PEM-файла, см. ParseKeyPEM
+func LoadKeyFile(kid, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ключа %q: %w", kid, err)
+	}
+	return ParseKeyPEM(kid, data)
+}
+
+var (
+	keysMu  sync.RWMutex
+	keyRing *KeyRing
+)
+
+// SetKeys задает ключ подписи и дополнительные ключи проверки, выбираемые по kid.
+// Ключ подписи тоже используется для проверки. signing может быть nil, если сервис только проверяет токены.
+// Пока ключи не заданы, токены подписываются HS256 с секретом из config.LoadJWTConfig без kid,
+// SetKeys(nil) возвращает это поведение. Токены, подписанные секретом до первого вызова, принимаются
+// еще DefaultKeyGracePeriod, см. SetKeyRing. Для ротации ключей используйте SetKeyRing
+func SetKeys(signing *Key, verification ...*Key) error {
+	if signing == nil && len(verification) == 0 {
+		SetKeyRing(nil)
+		return nil
+	}
+	ring, err := NewKeyRing(StaticKeys(signing, verification...))
+	if err != nil {
+		return err
+	}
+	SetKeyRing(ring)
+	return nil
+}
+
+// SetKeyRing задает кольцо ключей, которым подписываются и проверяются токены.
+// nil возвращает подпись HS256 с секретом из config.LoadJWTConfig.
+// Конфигурация JWT (издатель, получатель, секрет) перечитывается из окружения при следующем использовании.
+// При переходе с секрета на кольцо ключей токены без kid, подписанные секретом JWT_SECRET_KEY,
+// еще принимаются в течение льготного периода кольца. Чтобы перестать принимать их раньше
+// (например, после перезапуска сервиса), удалите JWT_SECRET_KEY из окружения
+func SetKeyRing(ring *KeyRing) {
+	resetConfig()
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if keyRing == nil && ring != nil {
+		if legacy := legacyKey(); legacy != nil {
+			ring.retire(legacy)
+		}
+	}
+	keyRing = ring
+}
+
+func currentKeyRing() *KeyRing {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keyRing
+}
+
+// currentSigningKey возвращает ключ подписи или ключ HS256 из конфигурации, если ключи не заданы
+func currentSigningKey() (*Key, error) {
+	if ring := currentKeyRing(); ring != nil {
+		return ring.Signing()
+	}
+	return configKey()
+}
+
+// lookupKey возвращает ключ проверки по kid или ключ HS256 из конфигурации, если ключи не заданы
+func lookupKey(kid string) (*Key, error) {
+	if ring := currentKeyRing(); ring != nil {
+		return ring.Lookup(kid)
+	}
+	if kid != "" {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return configKey()
+}
+
+var (
+	configMu  sync.Mutex
+	jwtConfig *config.JWTConfig // nil — конфигурация еще не загружена
+	secretKey *Key              // nil — ключ из конфигурации еще не загружен
+)
+
+// currentConfig возвращает конфигурацию JWT. Она загружается из окружения при первом вызове
+// и перечитывается после SetKeys и SetKeyRing, а не для каждого токена
+func currentConfig() *config.JWTConfig {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if jwtConfig == nil {
+		jwtConfig = config.LoadJWTConfig()
+	}
+	return jwtConfig
+}
+
+// resetConfig сбрасывает загруженную конфигурацию JWT, следующий вызов перечитает ее из окружения
+func resetConfig() {
+	configMu.Lock()
+	defer configMu.Unlock()
+	jwtConfig, secretKey = nil, nil
+}
+
+// configKey возвращает ключ HS256 с секретом из конфигурации. Ключ загружается один раз, ошибка не сохраняется.
+// В строгом режиме (config.Strict) секрет по умолчанию и короткий секрет не принимаются
+func configKey() (*Key, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if secretKey != nil {
+		return secretKey, nil
+	}
+
+	if !config.Strict() {
+		if jwtConfig == nil {
+			jwtConfig = config.LoadJWTConfig()
+		}
+		secretKey = NewHMACKey("", []byte(jwtConfig.SecretKey))
+		return secretKey, nil
+	}
+	cfg, err := config.LoadJWTConfigStrict()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SecretKey == "" {
+		return nil, errors.New("JWT_SECRET_KEY не задан, а кольцо ключей не загружено: вызовите LoadKeyRing")
+	}
+	secretKey = NewHMACKey("", []byte(cfg.SecretKey))
+	return secretKey, nil
+}
+
+// legacyKey возвращает ключ HS256 без kid с секретом JWT_SECRET_KEY, которым подписывались токены
+// до перехода на кольцо ключей, или nil, если секрет не задан или совпадает с известным значением по умолчанию
+func legacyKey() *Key {
+	secret := os.Getenv("JWT_SECRET_KEY")
+	if secret == "" || config.IsKnownDefault(secret) {
+		return nil
+	}
+	if config.Strict() && config.CheckSecret(secret) != nil {
+		return nil
+	}
+	return &Key{Method: jwt.SigningMethodHS256, verifyKey: []byte(secret)}
+}
+
+// publicKeys возвращает заданные асимметричные ключи проверки
+func publicKeys() []*Key {
+	ring := currentKeyRing()
+	if ring == nil {
+		return nil
+	}
+	var keys []*Key
+	for _, k := range ring.Keys() {
+		if _, ok := k.verifyKey.([]byte); !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
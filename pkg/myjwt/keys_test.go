@@ -0,0 +1,123 @@
+package myjwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// encodePEM кодирует DER в PEM-блок типа typ
+func encodePEM(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+// pkcs8PEM кодирует закрытый ключ в PEM PKCS #8
+func pkcs8PEM(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	return encodePEM("PRIVATE KEY", der)
+}
+
+// pkixPEM кодирует открытый ключ в PEM PKIX
+func pkixPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return encodePEM("PUBLIC KEY", der)
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ecKeys := map[elliptic.Curve]*ecdsa.PrivateKey{}
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		if ecKeys[curve], err = ecdsa.GenerateKey(curve, rand.Reader); err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+	}
+	sec1 := func(key *ecdsa.PrivateKey) []byte {
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("MarshalECPrivateKey: %v", err)
+		}
+		return encodePEM("EC PRIVATE KEY", der)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		method  jwt.SigningMethod
+		canSign bool
+	}{
+		{"RSA PKCS #8", pkcs8PEM(t, rsaKey), jwt.SigningMethodRS256, true},
+		{"RSA PKCS #1", encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), jwt.SigningMethodRS256, true},
+		{"RSA открытый PKIX", pkixPEM(t, &rsaKey.PublicKey), jwt.SigningMethodRS256, false},
+		{"RSA открытый PKCS #1", encodePEM("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), jwt.SigningMethodRS256, false},
+		{"P-256 SEC 1", sec1(ecKeys[elliptic.P256()]), jwt.SigningMethodES256, true},
+		{"P-384 PKCS #8", pkcs8PEM(t, ecKeys[elliptic.P384()]), jwt.SigningMethodES384, true},
+		{"P-521 открытый", pkixPEM(t, &ecKeys[elliptic.P521()].PublicKey), jwt.SigningMethodES512, false},
+		{"Ed25519 PKCS #8", pkcs8PEM(t, edKey), jwt.SigningMethodEdDSA, true},
+		{"Ed25519 открытый", pkixPEM(t, edKey.Public()), jwt.SigningMethodEdDSA, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseKeyPEM("k1", tt.data)
+			if err != nil {
+				t.Fatalf("ParseKeyPEM: %v", err)
+			}
+			if key.ID != "k1" || key.Method != tt.method || key.CanSign() != tt.canSign {
+				t.Errorf("ключ %q, %s, CanSign=%v, ожидалось k1, %s, %v",
+					key.ID, key.Method.Alg(), key.CanSign(), tt.method.Alg(), tt.canSign)
+			}
+		})
+	}
+}
+
+func TestParseKeyPEMRejects(t *testing.T) {
+	shortRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"RSA короче 2048 бит", pkcs8PEM(t, shortRSA)},
+		{"открытый RSA короче 2048 бит", pkixPEM(t, &shortRSA.PublicKey)},
+		{"кривая P-224", pkcs8PEM(t, p224)},
+		{"не PEM", []byte("not a key")},
+		{"неизвестный тип блока", encodePEM("CERTIFICATE REQUEST", []byte{1, 2, 3})},
+		{"поврежденный DER", encodePEM("PRIVATE KEY", []byte{1, 2, 3})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key, err := ParseKeyPEM("bad", tt.data); err == nil {
+				t.Errorf("ParseKeyPEM принял ключ %s", key.Method.Alg())
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+)
+
+// ErrTooManyAttempts — категория ошибки входа, временно заблокированного после неудачных попыток
+var ErrTooManyAttempts = errors.New("слишком много неудачных попыток входа")
+
+// LockedError возвращается Authenticate, пока действует блокировка по имени пользователя или IP-адресу.
+// errors.Is(err, ErrTooManyAttempts) == true
+type LockedError struct {
+	Until time.Time // Время окончания блокировки
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v, вход заблокирован до %s", ErrTooManyAttempts, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// LockoutPolicy задает блокировку входа по имени пользователя и по IP-адресу клиента
+type LockoutPolicy struct {
+	User database.LockoutRule
+	IP   database.LockoutRule
+}
+
+// DefaultLockoutPolicy — политика блокировки по умолчанию: имя пользователя блокируется после 5 неудачных
+// попыток подряд, IP-адрес — после 50, начиная с минуты и удваивая срок до часа
+var DefaultLockoutPolicy = LockoutPolicy{
+	User: database.LockoutRule{MaxFailures: 5, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: 24 * time.Hour},
+	IP:   database.LockoutRule{MaxFailures: 50, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: 24 * time.Hour},
+}
+
+// checkLockout возвращает *LockedError, если вход по имени пользователя или IP-адресу заблокирован
+func (a *Authenticator) checkLockout(ctx context.Context, username string, opts LoginOptions) error {
+	if a.attempts == nil {
+		return nil
+	}
+	keys := []string{database.UserLockoutKey(username)}
+	if opts.IP != "" {
+		keys = append(keys, database.IPLockoutKey(opts.IP))
+	}
+	until, err := a.attempts.LoginLockedUntil(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	if !until.IsZero() {
+		return &LockedError{Until: until}
+	}
+	return nil
+}
+
+// registerFailure учитывает неудачную попытку входа. userID пуст, если пользователь не найден
+func (a *Authenticator) registerFailure(ctx context.Context, userID, username string, opts LoginOptions) {
+	if a.attempts == nil {
+		return
+	}
+
+	until, err := a.attempts.RegisterLoginFailure(ctx, database.UserLockoutKey(username), a.policy.User)
+	if err != nil {
+		log.Printf("не удалось учесть неудачную попытку входа пользователя %s: %v", username, err)
+	} else if !until.IsZero() {
+		a.logAttempt(ctx, userID, fmt.Sprintf("Вход временно заблокирован до %s после неудачных попыток", until.Format(time.RFC3339)), opts)
+	}
+
+	if opts.IP == "" {
+		return
+	}
+	until, err = a.attempts.RegisterLoginFailure(ctx, database.IPLockoutKey(opts.IP), a.policy.IP)
+	if err != nil {
+		log.Printf("не удалось учесть неудачную попытку входа с IP %s: %v", opts.IP, err)
+	} else if !until.IsZero() {
+		a.logAttempt(ctx, userID, fmt.Sprintf("Вход с IP-адреса клиента временно заблокирован до %s после неудачных попыток", until.Format(time.RFC3339)), opts)
+	}
+}
+
+// resetFailures сбрасывает счетчик неудачных попыток по имени пользователя после успешного входа.
+// Счетчик по IP-адресу не сбрасывается, чтобы одна известная учетная запись не позволяла продолжать перебор
+func (a *Authenticator) resetFailures(ctx context.Context, username string) {
+	if a.attempts == nil {
+		return
+	}
+	if err := a.attempts.ResetLoginFailures(ctx, database.UserLockoutKey(username)); err != nil {
+		log.Printf("не удалось сбросить неудачные попытки входа пользователя %s: %v", username, err)
+	}
+}
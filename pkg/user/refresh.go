@@ -0,0 +1,41 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/myjwt"
+)
+
+// ErrTokenRevoked возвращается для токена, выданного до смены или сброса пароля или отозванного
+var ErrTokenRevoked = myjwt.ErrTokenRevoked
+
+// ValidateRefreshToken проверяет подпись и срок действия рефреш токена и то,
+// что он выдан после последней смены или сброса пароля пользователя
+func ValidateRefreshToken(ctx context.Context, creds database.CredentialRepository, tokenString string) (*myjwt.Claims, error) {
+	claims, err := myjwt.ValidateJWTContext(ctx, tokenString, myjwt.TypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt == nil {
+		return nil, errors.New("в токене нет времени выдачи")
+	}
+
+	validAfter, err := creds.TokensValidAfter(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if issuedBefore(claims, validAfter) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// issuedBefore сообщает, выдан ли токен не позже момента validAfter. iat хранится с точностью до секунды,
+// и по нему нельзя отличить токен, выданный в ту же секунду до отзыва, от выданного после.
+// Поэтому такие токены тоже считаются отозванными: действуют токены, выданные начиная со следующей секунды
+func issuedBefore(claims *myjwt.Claims, validAfter time.Time) bool {
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(validAfter.Truncate(time.Second))
+}
@@ -0,0 +1,172 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/myjwt"
+)
+
+// DefaultRevocationCacheTTL — сколько RevocationList помнит, что токен не отозван,
+// и момент TokensValidAfter пользователя. Отзыв на другом экземпляре сервиса вступает в силу не позже этого срока
+const DefaultRevocationCacheTTL = 30 * time.Second
+
+// RevocationStore — репозитории, которые использует RevocationList
+type RevocationStore interface {
+	database.RevocationRepository
+	database.CredentialRepository
+}
+
+type revocationEntry struct {
+	revoked bool
+	until   time.Time // Время вытеснения из кеша
+}
+
+type watermarkEntry struct {
+	validAfter time.Time
+	missing    bool // Пользователь удален или не существует
+	until      time.Time
+}
+
+// RevocationList проверяет отзыв токенов по хранилищу и кеширует результаты в памяти процесса.
+// Реализует myjwt.RevocationChecker: после myjwt.SetRevocationChecker(list) ValidateJWT отклоняет
+// отозванные токены и токены, выданные до RevokeAllForUser, смены пароля или удаления пользователя
+type RevocationList struct {
+	store RevocationStore
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu         sync.Mutex
+	tokens     map[string]revocationEntry // По jti
+	watermarks map[string]watermarkEntry  // По ID пользователя
+	nextSweep  time.Time
+}
+
+// Проверяем на этапе компиляции, что RevocationList реализует myjwt.RevocationChecker
+var _ myjwt.RevocationChecker = (*RevocationList)(nil)
+
+// NewRevocationList создает RevocationList. ttl — время жизни отрицательных результатов в кеше,
+// DefaultRevocationCacheTTL, если ttl <= 0. Отозванный токен хранится в кеше до истечения его срока
+func NewRevocationList(store RevocationStore, ttl time.Duration) *RevocationList {
+	if ttl <= 0 {
+		ttl = DefaultRevocationCacheTTL
+	}
+	return &RevocationList{
+		store:      store,
+		ttl:        ttl,
+		now:        time.Now,
+		tokens:     make(map[string]revocationEntry),
+		watermarks: make(map[string]watermarkEntry),
+	}
+}
+
+// IsRevoked сообщает, отозван ли токен
+func (l *RevocationList) IsRevoked(ctx context.Context, claims *myjwt.Claims) (bool, error) {
+	watermark, err := l.watermark(ctx, claims.Subject)
+	if err != nil {
+		return false, err
+	}
+	if watermark.missing || issuedBefore(claims, watermark.validAfter) {
+		return true, nil
+	}
+	return l.tokenRevoked(ctx, claims)
+}
+
+// RevokeToken отзывает токен до истечения его срока, например при выходе пользователя
+func (l *RevocationList) RevokeToken(ctx context.Context, claims *myjwt.Claims) error {
+	if claims.ExpiresAt == nil {
+		return errors.New("в токене нет времени истечения")
+	}
+	if err := l.store.RevokeToken(ctx, claims.Subject, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[claims.ID] = revocationEntry{revoked: true, until: claims.ExpiresAt.Time}
+	return nil
+}
+
+// RevokeAllForUser отзывает все выданные пользователю токены доступа и завершает все его сеансы
+func (l *RevocationList) RevokeAllForUser(ctx context.Context, userID string) error {
+	if err := l.store.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Новый момент будет прочитан из хранилища при следующей проверке
+	delete(l.watermarks, userID)
+	return nil
+}
+
+// watermark возвращает момент, до которого токены пользователя недействительны
+func (l *RevocationList) watermark(ctx context.Context, userID string) (watermarkEntry, error) {
+	now := l.now()
+	l.mu.Lock()
+	entry, ok := l.watermarks[userID]
+	l.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry, nil
+	}
+
+	validAfter, err := l.store.TokensValidAfter(ctx, userID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return watermarkEntry{}, err
+	}
+	entry = watermarkEntry{validAfter: validAfter, missing: err != nil, until: now.Add(l.ttl)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweepLocked(now)
+	l.watermarks[userID] = entry
+	return entry, nil
+}
+
+// tokenRevoked сообщает, отозван ли токен через RevokeToken
+func (l *RevocationList) tokenRevoked(ctx context.Context, claims *myjwt.Claims) (bool, error) {
+	now := l.now()
+	l.mu.Lock()
+	entry, ok := l.tokens[claims.ID]
+	l.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := l.store.TokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return false, err
+	}
+	entry = revocationEntry{revoked: revoked, until: now.Add(l.ttl)}
+	if revoked && claims.ExpiresAt != nil {
+		// Отзыв не отменяется, поэтому результат действителен до истечения токена
+		entry.until = claims.ExpiresAt.Time
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweepLocked(now)
+	l.tokens[claims.ID] = entry
+	return revoked, nil
+}
+
+// sweepLocked вытесняет из кеша устаревшие записи не чаще одного раза за ttl. Вызывается под l.mu
+func (l *RevocationList) sweepLocked(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	for jti, entry := range l.tokens {
+		if !now.Before(entry.until) {
+			delete(l.tokens, jti)
+		}
+	}
+	for userID, entry := range l.watermarks {
+		if !now.Before(entry.until) {
+			delete(l.watermarks, userID)
+		}
+	}
+	l.nextSweep = now.Add(l.ttl)
+}
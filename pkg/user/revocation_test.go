@@ -0,0 +1,151 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database/memstore"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/Maden-in-haven/crmlib/pkg/myjwt"
+	"github.com/Maden-in-haven/crmlib/pkg/user"
+)
+
+// hireDate — дата приема на работу менеджеров, создаваемых в тестах
+var hireDate = model.Date{Year: 2024, Month: time.March, Day: 1}
+
+// waitNextSecond ждет начала следующей секунды: iat хранится с точностью до секунды,
+// и токен, выданный в ту же секунду, что и отзыв, тоже считается отозванным
+func waitNextSecond() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+}
+
+func TestRevocation(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	sessions := user.NewSessions(r)
+	list := user.NewRevocationList(r, 0)
+	myjwt.SetRevocationChecker(list)
+	defer myjwt.SetRevocationChecker(nil)
+
+	id, err := r.CreateManager(ctx, uniqueName("manager"), "secret-password", "Менеджер", hireDate)
+	if err != nil {
+		t.Fatalf("CreateManager: %v", err)
+	}
+	manager, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	first, err := sessions.StartSession(ctx, manager, "conformance")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	claims, err := myjwt.ValidateJWT(first.AccessToken, myjwt.TypeAccess)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if err := list.RevokeToken(ctx, claims); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := myjwt.ValidateJWT(first.AccessToken, myjwt.TypeAccess); !errors.Is(err, myjwt.ErrTokenRevoked) {
+		t.Errorf("отозванный токен: ожидалась ErrTokenRevoked, получено %v", err)
+	}
+	// Другой экземпляр сервиса с пустым кешем видит отзыв через хранилище
+	if revoked, err := user.NewRevocationList(r, 0).IsRevoked(ctx, claims); err != nil || !revoked {
+		t.Errorf("IsRevoked без кеша = %v, %v", revoked, err)
+	}
+	if n, err := r.PurgeExpiredRevocations(ctx); err != nil || n != 0 {
+		t.Errorf("PurgeExpiredRevocations = %d, %v, действующие записи не должны удаляться", n, err)
+	}
+
+	second, err := sessions.StartSession(ctx, manager, "conformance")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := list.RevokeAllForUser(ctx, id); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+	if _, err := myjwt.ValidateJWT(second.AccessToken, myjwt.TypeAccess); !errors.Is(err, myjwt.ErrTokenRevoked) {
+		t.Errorf("токен после RevokeAllForUser: ожидалась ErrTokenRevoked, получено %v", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, second.RefreshToken); !errors.Is(err, user.ErrTokenRevoked) {
+		t.Errorf("рефреш токен после RevokeAllForUser: ожидалась ErrTokenRevoked, получено %v", err)
+	}
+
+	// Токены, выданные после отзыва, действуют
+	waitNextSecond()
+	third, err := sessions.StartSession(ctx, manager, "conformance")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	claims, err = myjwt.ValidateJWT(third.AccessToken, myjwt.TypeAccess)
+	if err != nil {
+		t.Fatalf("ValidateJWT нового токена: %v", err)
+	}
+
+	// Удаление менеджера отзывает его токены
+	if err := r.DeleteManager(ctx, id); err != nil {
+		t.Fatalf("DeleteManager: %v", err)
+	}
+	if revoked, err := user.NewRevocationList(r, 0).IsRevoked(ctx, claims); err != nil || !revoked {
+		t.Errorf("IsRevoked после DeleteManager = %v, %v", revoked, err)
+	}
+	if _, err := r.RestoreUser(ctx, id); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	if revoked, err := user.NewRevocationList(r, 0).IsRevoked(ctx, claims); err != nil || !revoked {
+		t.Errorf("IsRevoked после восстановления = %v, %v, токены удаленного менеджера не должны снова действовать", revoked, err)
+	}
+	if _, err := sessions.RefreshSession(ctx, third.RefreshToken); !errors.Is(err, user.ErrTokenRevoked) {
+		t.Errorf("рефреш токен после DeleteManager: ожидалась ErrTokenRevoked, получено %v", err)
+	}
+}
+
+func TestPasswordChangeRevokesTokens(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	sessions := user.NewSessions(r)
+
+	id, err := r.CreateClient(ctx, uniqueName("client"), "secret-password", "Клиент", "+79990000025")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	client, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	// Токен, выданный до смены пароля, перестает действовать
+	before, err := sessions.StartSession(ctx, client, "test")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := r.ChangePassword(ctx, id, "secret-password", "new-secret-password"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := user.ValidateRefreshToken(ctx, r, before.RefreshToken); !errors.Is(err, user.ErrTokenRevoked) {
+		t.Errorf("рефреш токен после смены пароля: ожидалась ErrTokenRevoked, получено %v", err)
+	}
+
+	waitNextSecond()
+	after, err := sessions.StartSession(ctx, client, "test")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if _, err := user.ValidateRefreshToken(ctx, r, after.RefreshToken); err != nil {
+		t.Errorf("рефреш токен после смены пароля: %v", err)
+	}
+
+	token, err := r.CreatePasswordResetToken(ctx, id, time.Hour)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken: %v", err)
+	}
+	if err := r.ResetPassword(ctx, token, "reset-secret-password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := user.ValidateRefreshToken(ctx, r, after.RefreshToken); !errors.Is(err, user.ErrTokenRevoked) {
+		t.Errorf("рефреш токен после сброса пароля: ожидалась ErrTokenRevoked, получено %v", err)
+	}
+}
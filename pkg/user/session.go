@@ -0,0 +1,117 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/Maden-in-haven/crmlib/pkg/myjwt"
+)
+
+// TokenPair — токены, выдаваемые при входе и при каждой ротации
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	SessionID    string // ID семейства рефреш токенов, по нему сеанс завершается через EndSession
+}
+
+// SessionStore — репозитории, которые использует Sessions
+type SessionStore interface {
+	database.UserLookup
+	database.AdminRepository
+	database.CredentialRepository
+	database.RefreshTokenRepository
+}
+
+// Sessions выдает и ротирует токены сеансов. Рефреш токен принимается один раз:
+// при ротации выдается новая пара, а повторное предъявление использованного токена отзывает весь сеанс
+type Sessions struct {
+	store SessionStore
+}
+
+// NewSessions создает Sessions поверх хранилища
+func NewSessions(store SessionStore) *Sessions {
+	return &Sessions{store: store}
+}
+
+// StartSession начинает сеанс пользователя, прошедшего аутентификацию, на устройстве device
+// (например, название приложения и модель телефона) и выдает первую пару токенов
+func (s *Sessions) StartSession(ctx context.Context, user model.User, device string) (TokenPair, error) {
+	claims, err := myjwt.NewClaims(myjwt.TypeRefresh, user.ID, myjwt.RefreshTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair, err := s.issue(ctx, user, claims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	record, err := s.store.CreateRefreshToken(ctx, user.ID, claims.ID, device, claims.ExpiresAt.Time)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair.SessionID = record.FamilyID
+	return pair, nil
+}
+
+// RefreshSession проверяет рефреш токен и выдает новую пару токенов, а предъявленный токен становится использованным.
+// Новая пара подписывается до ротации, поэтому ошибка подписи не расходует предъявленный токен.
+// Если токен уже использован, сеанс отзывается и возвращается ошибка, для которой
+// errors.Is(err, database.ErrTokenReused) == true. Для токенов, выданных до смены пароля, возвращается ErrTokenRevoked
+func (s *Sessions) RefreshSession(ctx context.Context, refreshToken string) (TokenPair, error) {
+	claims, err := ValidateRefreshToken(ctx, s.store, refreshToken)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	user, err := s.store.GetUserByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return TokenPair{}, &database.Error{Kind: database.ErrInvalidToken, Msg: "рефреш токен недействителен или истек"}
+		}
+		return TokenPair{}, err
+	}
+	next, err := myjwt.NewClaims(myjwt.TypeRefresh, user.ID, myjwt.RefreshTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair, err := s.issue(ctx, user, next)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	record, err := s.store.RotateRefreshToken(ctx, claims.ID, next.ID, next.ExpiresAt.Time)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair.SessionID = record.FamilyID
+	return pair, nil
+}
+
+// EndSession завершает сеанс: все рефреш токены семейства sessionID отзываются
+func (s *Sessions) EndSession(ctx context.Context, sessionID string) error {
+	return s.store.RevokeRefreshFamily(ctx, sessionID)
+}
+
+// issue подписывает рефреш токен и выдает к нему токен доступа с ролью и правами пользователя.
+// SessionID заполняет вызывающий код после сохранения рефреш токена
+func (s *Sessions) issue(ctx context.Context, user model.User, refresh *myjwt.Claims) (TokenPair, error) {
+	var scopes []string
+	if user.Role == "admin" {
+		admin, err := s.store.GetAdminByID(ctx, user.ID)
+		if err != nil {
+			return TokenPair{}, err
+		}
+		scopes = myjwt.Scopes(admin.Permissions)
+	}
+
+	accessToken, err := myjwt.GenerateJWT(user.ID, user.Role, scopes)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshToken, err := myjwt.Sign(refresh)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
@@ -0,0 +1,82 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/database/memstore"
+	"github.com/Maden-in-haven/crmlib/pkg/myjwt"
+	"github.com/Maden-in-haven/crmlib/pkg/user"
+)
+
+func TestRefreshSession(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	sessions := user.NewSessions(r)
+
+	id, err := r.CreateAdmin(ctx, uniqueName("admin"), "secret-password", map[string]interface{}{"users": true, "reports": false})
+	if err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+	admin, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	first, err := sessions.StartSession(ctx, admin, "conformance")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	claims, err := myjwt.ValidateJWT(first.AccessToken, myjwt.TypeAccess)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if claims.Subject != id || claims.Role != "admin" || !claims.HasScope("users") || claims.HasScope("reports") {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := myjwt.ValidateJWT(first.RefreshToken, myjwt.TypeAccess); !errors.Is(err, myjwt.ErrUnexpectedType) {
+		t.Errorf("рефреш токен как токен доступа: ожидалась ErrUnexpectedType, получено %v", err)
+	}
+
+	second, err := sessions.RefreshSession(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if second.SessionID != first.SessionID || second.RefreshToken == first.RefreshToken {
+		t.Errorf("ротация: сеанс %q -> %q", first.SessionID, second.SessionID)
+	}
+	third, err := sessions.RefreshSession(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+
+	// Повторное предъявление использованного токена отзывает весь сеанс
+	if _, err := sessions.RefreshSession(ctx, first.RefreshToken); !errors.Is(err, database.ErrTokenReused) || !errors.Is(err, database.ErrInvalidToken) {
+		t.Fatalf("повторный токен: ожидалась ErrTokenReused, получено %v", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, third.RefreshToken); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("токен отозванного сеанса: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	// Токен, не выданный через сеанс, не принимается
+	stateless, err := myjwt.GenerateRefreshToken(id)
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, stateless); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("токен без сеанса: ожидалась ErrInvalidToken, получено %v", err)
+	}
+
+	other, err := sessions.StartSession(ctx, admin, "conformance")
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := sessions.EndSession(ctx, other.SessionID); err != nil {
+		t.Fatalf("EndSession: %v", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, other.RefreshToken); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("токен завершенного сеанса: ожидалась ErrInvalidToken, получено %v", err)
+	}
+}
@@ -2,26 +2,300 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
 
 	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/mfa"
 	"github.com/Maden-in-haven/crmlib/pkg/model"
 	"github.com/Maden-in-haven/crmlib/pkg/util"
 )
 
-// Функция для аутентификации пользователя
-func AuthenticateUser(username, password string) (model.User, error) {
-	// Находим пользователя по username
-	user, err := database.DB.GetUserByUsername(context.Background(), username)
+// ErrInvalidCredentials возвращается при любой неудачной проверке имени пользователя и пароля,
+// чтобы по ответу нельзя было узнать, существует ли пользователь
+var ErrInvalidCredentials = errors.New("неверное имя пользователя или пароль")
+
+// ErrMFARequired возвращается AuthenticateUser, если пароль верен, но для входа нужен второй фактор
+var ErrMFARequired = errors.New("требуется двухфакторная аутентификация")
+
+// DefaultMFARoles — роли, для которых двухфакторная аутентификация обязательна
+var DefaultMFARoles = []string{"admin"}
+
+// Store — репозитории, которые использует Authenticator
+type Store interface {
+	database.UserLookup
+	database.AuditLogRepository
+	database.LoginAttemptRepository
+	database.MFARepository
+}
+
+// LoginOptions — сведения о клиенте, которые записываются в журнал вместе с попыткой входа
+type LoginOptions struct {
+	IP        string
+	UserAgent string
+}
+
+// Result — результат проверки пароля
+type Result struct {
+	User model.User
+	// MFARequired — пароль верен, но вход нужно завершить вызовом CompleteMFA
+	MFARequired bool
+	// MFAEnrolled — второй фактор подключен. Если MFARequired и второй фактор не подключен,
+	// пользователь должен сначала подключить его через mfa.Service (Enroll и Confirm)
+	MFAEnrolled bool
+}
+
+// Authenticator проверяет имя пользователя и пароль, записывает попытки входа в user_logs,
+// временно блокирует вход после серии неудачных попыток и требует второй фактор
+type Authenticator struct {
+	users    database.UserLookup
+	logs     database.AuditLogRepository     // nil — попытки не записываются
+	attempts database.LoginAttemptRepository // nil — вход не блокируется
+	policy   LockoutPolicy
+	mfa      *mfa.Service // nil — подключение второго фактора неизвестно, он требуется только от ролей mfaRoles
+	mfaRoles map[string]bool
+}
+
+// Option настраивает Authenticator
+type Option func(*Authenticator)
+
+// WithLockoutPolicy задает политику блокировки входа вместо DefaultLockoutPolicy
+func WithLockoutPolicy(policy LockoutPolicy) Option {
+	return func(a *Authenticator) {
+		a.policy = policy
+	}
+}
+
+// WithMFA задает сервис двухфакторной аутентификации вместо созданного по умолчанию mfa.New(store)
+func WithMFA(service *mfa.Service) Option {
+	return func(a *Authenticator) {
+		a.mfa = service
+	}
+}
+
+// WithMFARoles задает роли, для которых второй фактор обязателен, вместо DefaultMFARoles.
+// Для пользователей остальных ролей второй фактор требуется, только если они подключили его сами
+func WithMFARoles(roles ...string) Option {
+	return func(a *Authenticator) {
+		a.mfaRoles = roleSet(roles)
+	}
+}
+
+// NewAuthenticator создает Authenticator поверх хранилища
+func NewAuthenticator(store Store, opts ...Option) *Authenticator {
+	a := &Authenticator{
+		users:    store,
+		logs:     store,
+		attempts: store,
+		policy:   DefaultLockoutPolicy,
+		mfaRoles: roleSet(DefaultMFARoles),
+	}
+	// Если сервис по умолчанию создать нельзя (измененный mfa.DefaultTOTP некорректен),
+	// второй фактор требуется только от ролей mfaRoles, пока WithMFA не задаст другой сервис
+	if service, err := mfa.New(store); err != nil {
+		log.Print(err)
+	} else {
+		a.mfa = service
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	// Фиктивный хеш создается заранее, чтобы первый вход с неизвестным именем не был дольше остальных.
+	// При ошибке Authenticate попробует создать его снова
+	if _, err := dummyPasswordHash(); err != nil {
+		log.Print(err)
+	}
+	return a
+}
+
+var (
+	dummyMu   sync.Mutex
+	dummyHash string
+)
+
+// dummyPasswordHash возвращает хеш случайного пароля для сравнения, когда пользователь не найден.
+// Хеш создается хешером по умолчанию, поэтому время проверки совпадает со временем для реальных пользователей.
+// Ошибка создания хеша не сохраняется: следующий вызов пробует снова
+func dummyPasswordHash() (string, error) {
+	dummyMu.Lock()
+	defer dummyMu.Unlock()
+	if dummyHash != "" {
+		return dummyHash, nil
+	}
+	token, _, err := database.NewResetToken()
 	if err != nil {
-		return model.User{}, err
+		return "", fmt.Errorf("не удалось создать фиктивный хеш пароля: %w", err)
+	}
+	hash, err := util.HashPassword(token)
+	if err != nil {
+		return "", fmt.Errorf("не удалось создать фиктивный хеш пароля: %w", err)
+	}
+	dummyHash = hash
+	return dummyHash, nil
+}
+
+// Authenticate проверяет имя пользователя и пароль. При любой ошибке учетных данных возвращает
+// ErrInvalidCredentials, а для неизвестного пользователя выполняет фиктивную проверку пароля
+// и записывает попытку в журнал без привязки к пользователю, чтобы время ответа не выдавало
+// существование учетной записи.
+// Пока действует блокировка по имени пользователя или opts.IP, пароль не проверяется
+// и возвращается *LockedError (errors.Is(err, ErrTooManyAttempts)).
+// Если хеш пароля создан устаревшим алгоритмом или с другими параметрами,
+// после успешной проверки он пересчитывается хешером по умолчанию.
+// Если для пользователя нужен второй фактор, возвращается Result с MFARequired: вход не завершен,
+// пока CompleteMFA не примет код
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string, opts LoginOptions) (Result, error) {
+	if err := a.checkLockout(ctx, username, opts); err != nil {
+		return Result{}, err
+	}
+
+	user, err := a.users.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// Выполняем ту же работу, что и при неверном пароле известного пользователя
+			dummy, err := dummyPasswordHash()
+			if err != nil {
+				return Result{}, err
+			}
+			_ = util.CheckPassword(dummy, password)
+			a.logAttempt(ctx, "", "Неудачная попытка входа", opts)
+			a.registerFailure(ctx, "", username, opts)
+			return Result{}, ErrInvalidCredentials
+		}
+		return Result{}, err
 	}
 
-	// Проверяем пароль
-	err = util.CheckPassword(user.PasswordHash, password)
+	if err := util.CheckPassword(user.PasswordHash, password); err != nil {
+		if !errors.Is(err, util.ErrMismatchedPassword) {
+			// Например, пустой или поврежденный хеш. Пользователю об этом не сообщаем
+			log.Printf("ошибка проверки пароля пользователя %s: %v", user.ID, err)
+		}
+		a.logAttempt(ctx, user.ID, "Неудачная попытка входа", opts)
+		a.registerFailure(ctx, user.ID, username, opts)
+		return Result{}, ErrInvalidCredentials
+	}
+
+	// Пересчитываем хеш. Ошибка не мешает входу: пароль уже проверен, хеш обновится при следующем входе
+	if util.NeedsRehash(user.PasswordHash) {
+		if err := rehash(ctx, a.users, &user, password); err != nil {
+			log.Printf("не удалось пересчитать хеш пароля пользователя %s: %v", user.ID, err)
+		}
+	}
+
+	result := Result{User: user}
+	result.MFARequired, result.MFAEnrolled, err = a.mfaRequired(ctx, user)
+	if err != nil {
+		return Result{}, err
+	}
+	if result.MFARequired {
+		// Счетчик неудачных попыток не сбрасываем до проверки второго фактора,
+		// иначе знание пароля позволило бы перебирать коды без блокировки
+		a.logAttempt(ctx, user.ID, "Пароль принят, требуется второй фактор", opts)
+		return result, nil
+	}
+
+	a.resetFailures(ctx, username)
+	a.logAttempt(ctx, user.ID, "Успешный вход", opts)
+	return result, nil
+}
+
+// CompleteMFA завершает вход пользователя userID кодом из приложения-аутентификатора или кодом восстановления.
+// Вызывается только после Authenticate с MFARequired: сохранить userID между шагами входа
+// (например, в короткоживущем токене) должен вызывающий код.
+// Для неверного или уже использованного кода возвращается mfa.ErrInvalidCode, неудачная попытка учитывается в блокировке
+func (a *Authenticator) CompleteMFA(ctx context.Context, userID, code string, opts LoginOptions) (model.User, error) {
+	user, err := a.users.GetUserByID(ctx, userID)
 	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return model.User{}, ErrInvalidCredentials
+		}
+		return model.User{}, err
+	}
+	if err := a.checkLockout(ctx, user.Username, opts); err != nil {
+		return model.User{}, err
+	}
+	if a.mfa == nil {
+		return model.User{}, mfa.ErrNotEnrolled
+	}
+
+	if err := a.mfa.Verify(ctx, user.ID, code); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			a.logAttempt(ctx, user.ID, "Неверный код двухфакторной аутентификации", opts)
+			a.registerFailure(ctx, user.ID, user.Username, opts)
+		}
 		return model.User{}, err
 	}
 
-	// Возвращаем пользователя, если пароль верен
+	a.resetFailures(ctx, user.Username)
+	a.logAttempt(ctx, user.ID, "Успешный вход с двухфакторной аутентификацией", opts)
 	return user, nil
 }
+
+// mfaRequired сообщает, нужен ли пользователю второй фактор и подключен ли он.
+// Если подключение второго фактора проверить нельзя, он требуется только от ролей mfaRoles
+func (a *Authenticator) mfaRequired(ctx context.Context, user model.User) (required, enrolled bool, err error) {
+	if a.mfa == nil {
+		return a.mfaRoles[user.Role], false, nil
+	}
+	if enrolled, err = a.mfa.Enabled(ctx, user.ID); err != nil {
+		return false, false, err
+	}
+	return enrolled || a.mfaRoles[user.Role], enrolled, nil
+}
+
+// logAttempt записывает попытку входа в журнал. Ошибка записи не влияет на результат входа
+func (a *Authenticator) logAttempt(ctx context.Context, userID, action string, opts LoginOptions) {
+	if a.logs == nil {
+		return
+	}
+	if err := a.logs.LogAction(ctx, userID, fmt.Sprintf("%s (IP: %s, User-Agent: %s)", action, opts.IP, opts.UserAgent)); err != nil {
+		log.Printf("не удалось записать попытку входа пользователя %s: %v", userID, err)
+	}
+}
+
+// Функция для аутентификации пользователя.
+//
+// Если users реализует Store, вход проверяется так же, как NewAuthenticator(users).Authenticate:
+// с журналом, блокировкой и проверкой второго фактора. Иначе подключение второго фактора проверить нельзя,
+// и он требуется только от ролей DefaultMFARoles: для них возвращается ErrMFARequired.
+// Если второй фактор нужен, также возвращается ErrMFARequired.
+//
+// Deprecated: не принимает контекст и сведения о клиенте и не позволяет завершить вход вторым фактором,
+// используйте Authenticator.Authenticate.
+func AuthenticateUser(users database.UserLookup, username, password string) (model.User, error) {
+	a := &Authenticator{users: users, mfaRoles: roleSet(DefaultMFARoles)}
+	if store, ok := users.(Store); ok {
+		a = NewAuthenticator(store)
+	}
+	result, err := a.Authenticate(context.Background(), username, password, LoginOptions{})
+	if err != nil {
+		return model.User{}, err
+	}
+	if result.MFARequired {
+		return model.User{}, ErrMFARequired
+	}
+	return result.User, nil
+}
+
+// rehash пересчитывает хеш пароля и сохраняет его
+func rehash(ctx context.Context, users database.UserLookup, user *model.User, password string) error {
+	passwordHash, err := util.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := users.UpdatePasswordHash(ctx, user.ID, passwordHash); err != nil {
+		return err
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func roleSet(roles []string) map[string]bool {
+	set := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		set[role] = true
+	}
+	return set
+}
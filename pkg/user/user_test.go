@@ -0,0 +1,314 @@
+package user_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Maden-in-haven/crmlib/pkg/database"
+	"github.com/Maden-in-haven/crmlib/pkg/database/memstore"
+	"github.com/Maden-in-haven/crmlib/pkg/mfa"
+	"github.com/Maden-in-haven/crmlib/pkg/model"
+	"github.com/Maden-in-haven/crmlib/pkg/user"
+	"github.com/Maden-in-haven/crmlib/pkg/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// uniqueName возвращает имя пользователя, не пересекающееся с другими тестами
+func uniqueName(prefix string) string {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return prefix + "_" + hex.EncodeToString(b[:])
+}
+
+// totpCode возвращает код TOTP для шага, отстоящего на offset от текущего
+func totpCode(t *testing.T, totp mfa.TOTP, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	return code
+}
+
+func TestAuthenticate(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	auth := user.NewAuthenticator(r)
+	opts := user.LoginOptions{IP: "192.0.2.1", UserAgent: "conformance"}
+	username := uniqueName("client")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000020")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	got, err := auth.Authenticate(ctx, username, "secret-password", opts)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.User.ID != id || got.MFARequired {
+		t.Errorf("Authenticate = %+v, ожидался вход пользователя %q без второго фактора", got, id)
+	}
+
+	// Неверный пароль и неизвестный пользователь неразличимы по ошибке
+	if _, err := auth.Authenticate(ctx, username, "wrong-password", opts); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Errorf("неверный пароль: ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+	before := len(r.Logs())
+	if _, err := auth.Authenticate(ctx, uniqueName("nobody"), "secret-password", opts); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Errorf("неизвестный пользователь: ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+	// Попытка с неизвестным именем записывается в журнал без пользователя, как и неверный пароль
+	if logs := r.Logs(); len(logs) != before+1 || logs[before].UserID != "" {
+		t.Errorf("журнал после входа неизвестного пользователя: %+v", logs[before:])
+	}
+
+	if err := r.DeleteClient(ctx, id); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, username, "secret-password", opts); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Errorf("удаленный пользователь: ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+}
+
+func TestAuthenticateRehash(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+
+	id, err := r.CreateClient(ctx, "rehash", "secret-password", "Клиент", "+79990000016")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	// Учетная запись, созданная до перехода на Argon2id
+	legacy, err := util.NewBcryptHasher(bcrypt.MinCost).Hash("secret-password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if err := r.UpdatePasswordHash(ctx, id, legacy); err != nil {
+		t.Fatalf("UpdatePasswordHash: %v", err)
+	}
+
+	// Вход пересчитывает хеш хешером по умолчанию
+	if _, err := user.NewAuthenticator(r).Authenticate(ctx, "rehash", "secret-password", user.LoginOptions{}); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	got, err := r.GetUserByUsername(ctx, "rehash")
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if util.NeedsRehash(got.PasswordHash) {
+		t.Errorf("хеш %q не пересчитан после входа", got.PasswordHash)
+	}
+	if err := util.CheckPassword(got.PasswordHash, "secret-password"); err != nil {
+		t.Errorf("CheckPassword после пересчета: %v", err)
+	}
+}
+
+func TestAuthenticateLockout(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	policy := user.LockoutPolicy{
+		User: database.LockoutRule{MaxFailures: 2, BaseLockout: time.Hour, MaxLockout: time.Hour, Window: time.Hour},
+		IP:   database.LockoutRule{MaxFailures: 3, BaseLockout: time.Hour, MaxLockout: time.Hour, Window: time.Hour},
+	}
+	auth := user.NewAuthenticator(r, user.WithLockoutPolicy(policy))
+	opts := user.LoginOptions{IP: "192.0.2.20", UserAgent: "conformance"}
+	username := uniqueName("client")
+
+	id, err := r.CreateClient(ctx, username, "secret-password", "Клиент", "+79990000021")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := auth.Authenticate(ctx, username, "wrong-password", opts); !errors.Is(err, user.ErrInvalidCredentials) {
+			t.Fatalf("попытка %d: ожидалась ErrInvalidCredentials, получено %v", i+1, err)
+		}
+	}
+	// Верный пароль не помогает, пока действует блокировка
+	_, err = auth.Authenticate(ctx, username, "secret-password", user.LoginOptions{IP: "192.0.2.21"})
+	var locked *user.LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, user.ErrTooManyAttempts) {
+		t.Fatalf("ожидалась LockedError, получено %v", err)
+	}
+
+	if err := r.UnlockUser(ctx, id); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, username, "secret-password", user.LoginOptions{IP: "192.0.2.21"}); err != nil {
+		t.Fatalf("Authenticate после UnlockUser: %v", err)
+	}
+
+	// Блокировка по IP действует и для других имен, в том числе несуществующих
+	if _, err := auth.Authenticate(ctx, uniqueName("nobody"), "wrong-password", opts); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Fatalf("ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, username, "secret-password", opts); !errors.Is(err, user.ErrTooManyAttempts) {
+		t.Errorf("вход с заблокированного IP: ожидалась ErrTooManyAttempts, получено %v", err)
+	}
+}
+
+func TestAuthenticateMFA(t *testing.T) {
+	r := memstore.New()
+	ctx := context.Background()
+	auth := user.NewAuthenticator(r)
+	service, err := mfa.New(r)
+	if err != nil {
+		t.Fatalf("mfa.New: %v", err)
+	}
+	opts := user.LoginOptions{IP: "192.0.2.30", UserAgent: "conformance"}
+	username := uniqueName("admin")
+
+	id, err := r.CreateAdmin(ctx, username, "secret-password", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+
+	// Администратору второй фактор нужен, даже если он еще не подключен
+	result, err := auth.Authenticate(ctx, username, "secret-password", opts)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !result.MFARequired || result.MFAEnrolled || result.User.ID != id {
+		t.Fatalf("Authenticate = %+v, ожидался запрос второго фактора без подключения", result)
+	}
+	if _, err := user.AuthenticateUser(r, username, "secret-password"); !errors.Is(err, user.ErrMFARequired) {
+		t.Errorf("AuthenticateUser: ожидалась ErrMFARequired, получено %v", err)
+	}
+	if _, err := auth.CompleteMFA(ctx, id, "000000", opts); !errors.Is(err, mfa.ErrNotEnrolled) {
+		t.Errorf("CompleteMFA без подключения: ожидалась ErrNotEnrolled, получено %v", err)
+	}
+
+	enrollment, err := service.Enroll(ctx, id, username)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	codes, err := service.Confirm(ctx, id, totpCode(t, service.TOTP(), enrollment.Secret, 0))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+
+	result, err = auth.Authenticate(ctx, username, "secret-password", opts)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !result.MFARequired || !result.MFAEnrolled {
+		t.Fatalf("Authenticate = %+v, ожидался запрос второго фактора", result)
+	}
+	if _, err := auth.CompleteMFA(ctx, id, "wrong-code", opts); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("CompleteMFA с неверным кодом: ожидалась ErrInvalidCode, получено %v", err)
+	}
+	got, err := auth.CompleteMFA(ctx, id, totpCode(t, service.TOTP(), enrollment.Secret, 1), opts)
+	if err != nil {
+		t.Fatalf("CompleteMFA: %v", err)
+	}
+	if got.ID != id {
+		t.Errorf("ID = %q, ожидался %q", got.ID, id)
+	}
+	if _, err := auth.CompleteMFA(ctx, id, codes[0], opts); err != nil {
+		t.Errorf("CompleteMFA с кодом восстановления: %v", err)
+	}
+
+	// Клиент может подключить второй фактор добровольно, без подключения он не требуется
+	clientName := uniqueName("client")
+	if _, err := r.CreateClient(ctx, clientName, "secret-password", "Клиент", "+79990000022"); err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if result, err := auth.Authenticate(ctx, clientName, "secret-password", opts); err != nil || result.MFARequired {
+		t.Errorf("Authenticate клиента = %+v, %v, второй фактор не ожидался", result, err)
+	}
+	if _, err := user.AuthenticateUser(r, clientName, "secret-password"); err != nil {
+		t.Errorf("AuthenticateUser клиента: %v", err)
+	}
+	// Без доступа к настройкам второго фактора он требуется только от ролей DefaultMFARoles
+	if _, err := user.AuthenticateUser(usersOnly{r}, clientName, "secret-password"); err != nil {
+		t.Errorf("AuthenticateUser клиента без MFARepository: %v", err)
+	}
+	if _, err := user.AuthenticateUser(usersOnly{r}, username, "secret-password"); !errors.Is(err, user.ErrMFARequired) {
+		t.Errorf("AuthenticateUser администратора без MFARepository: ожидалась ErrMFARequired, получено %v", err)
+	}
+
+	clientID, err := r.CreateClient(ctx, uniqueName("client"), "secret-password", "Клиент", "+79990000026")
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	client, err := r.GetUserByID(ctx, clientID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	enrollment, err = service.Enroll(ctx, clientID, client.Username)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if _, err := service.Confirm(ctx, clientID, totpCode(t, service.TOTP(), enrollment.Secret, 0)); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if _, err := user.AuthenticateUser(r, client.Username, "secret-password"); !errors.Is(err, user.ErrMFARequired) {
+		t.Errorf("AuthenticateUser клиента с подключенным вторым фактором: ожидалась ErrMFARequired, получено %v", err)
+	}
+}
+
+// usersOnly скрывает все методы хранилища, кроме database.UserLookup
+type usersOnly struct {
+	database.UserLookup
+}
+
+// mockUsers — database.UserLookup без базы данных, как его подменяют в тестах потребителей
+type mockUsers struct {
+	users map[string]model.User // По имени пользователя
+}
+
+func (m mockUsers) GetUserByID(ctx context.Context, userID string) (model.User, error) {
+	for _, u := range m.users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return model.User{}, database.NotFound("пользователь с ID %s не найден", userID)
+}
+
+func (m mockUsers) GetUserByUsername(ctx context.Context, username string) (model.User, error) {
+	u, ok := m.users[username]
+	if !ok {
+		return model.User{}, database.NotFound("пользователь %s не найден", username)
+	}
+	return u, nil
+}
+
+func (m mockUsers) UpdatePasswordHash(ctx context.Context, userID, passwordHash string) error {
+	return nil
+}
+
+func TestAuthenticateUserMock(t *testing.T) {
+	hash, err := util.HashPassword("secret-password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	users := mockUsers{users: map[string]model.User{
+		"manager": {ID: "00000000-0000-0000-0000-000000000001", Username: "manager", Role: "manager", PasswordHash: hash},
+		"admin":   {ID: "00000000-0000-0000-0000-000000000002", Username: "admin", Role: "admin", PasswordHash: hash},
+	}}
+
+	got, err := user.AuthenticateUser(users, "manager", "secret-password")
+	if err != nil {
+		t.Fatalf("AuthenticateUser менеджера: %v", err)
+	}
+	if got.ID != users.users["manager"].ID {
+		t.Errorf("ID = %q, ожидался %q", got.ID, users.users["manager"].ID)
+	}
+	if _, err := user.AuthenticateUser(users, "manager", "wrong-password"); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Errorf("неверный пароль: ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+	if _, err := user.AuthenticateUser(users, "nobody", "secret-password"); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Errorf("неизвестный пользователь: ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+	if _, err := user.AuthenticateUser(users, "admin", "secret-password"); !errors.Is(err, user.ErrMFARequired) {
+		t.Errorf("администратор: ожидалась ErrMFARequired, получено %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrMismatchedPassword возвращается, если пароль не соответствует хешу
+var ErrMismatchedPassword = errors.New("пароль не соответствует хешу")
+
+// ErrUnknownHashFormat возвращается, если алгоритм хеша не удалось определить
+var ErrUnknownHashFormat = errors.New("неизвестный формат хеша пароля")
+
+// PasswordHasher хеширует и проверяет пароли одним алгоритмом
+type PasswordHasher interface {
+	// Hash возвращает хеш пароля вместе с солью и параметрами алгоритма
+	Hash(password string) (string, error)
+	// Verify возвращает ErrMismatchedPassword, если пароль не соответствует хешу
+	Verify(hash, password string) error
+	// Supports сообщает, что hash создан алгоритмом этого хешера
+	Supports(hash string) bool
+	// NeedsRehash сообщает, что hash создан другим алгоритмом или с другими параметрами
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher хеширует пароли алгоритмом bcrypt
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher создает хешер bcrypt. Стоимость вне допустимого диапазона заменяется на bcrypt.DefaultCost
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func (h *BcryptHasher) Verify(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatchedPassword
+	}
+	return err
+}
+
+func (h *BcryptHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	if !h.Supports(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idParams — параметры Argon2id
+type Argon2idParams struct {
+	Memory      uint32 // Память в КиБ
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32 // Длина соли в байтах
+	KeyLength   uint32 // Длина хеша в байтах
+}
+
+// MaxArgon2idMemory — наибольший допустимый объем памяти Argon2id в КиБ (1 ГиБ). Ограничивает стоимость
+// проверки хеша с завышенными параметрами
+const MaxArgon2idMemory = 1024 * 1024
+
+// Validate проверяет, что параметры допустимы для Argon2id: число итераций и потоков не меньше 1,
+// соль и хеш не пустые, объем памяти не больше MaxArgon2idMemory
+func (p Argon2idParams) Validate() error {
+	switch {
+	case p.Iterations < 1:
+		return errors.New("число итераций argon2id должно быть не меньше 1")
+	case p.Parallelism < 1:
+		return errors.New("число потоков argon2id должно быть не меньше 1")
+	case p.SaltLength < 1:
+		return errors.New("соль argon2id не может быть пустой")
+	case p.KeyLength < 1:
+		return errors.New("длина хеша argon2id должна быть не меньше 1")
+	case p.Memory < 1 || p.Memory > MaxArgon2idMemory:
+		return fmt.Errorf("объем памяти argon2id должен быть от 1 до %d КиБ", MaxArgon2idMemory)
+	}
+	return nil
+}
+
+// DefaultArgon2idParams — параметры из рекомендаций RFC 9106 для систем с ограниченной памятью
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idHasher хеширует пароли алгоритмом Argon2id.
+// Хеш записывается в формате PHC: $argon2id$v=19$m=65536,t=3,p=4$<соль>$<хеш>
+type Argon2idHasher struct {
+	Params Argon2idParams
+}
+
+// NewArgon2idHasher создает хешер Argon2id с параметрами params.
+// Возвращает ошибку, если параметры недопустимы (см. Argon2idParams.Validate)
+func NewArgon2idHasher(params Argon2idParams) (*Argon2idHasher, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return &Argon2idHasher{Params: params}, nil
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	if err := h.Params.Validate(); err != nil {
+		return "", err
+	}
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(hash, password string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+func (h *Argon2idHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	return err != nil || params != h.Params
+}
+
+// decodeArgon2id разбирает хеш в формате PHC
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHashFormat, err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: неподдерживаемая версия argon2 %d", ErrUnknownHashFormat, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHashFormat, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHashFormat, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHashFormat, err)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	if err := params.Validate(); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHashFormat, err)
+	}
+	return params, salt, key, nil
+}
+
+var (
+	hasherMu sync.RWMutex
+	// defaultHasher хеширует пароли новых учетных записей
+	defaultHasher PasswordHasher = &Argon2idHasher{Params: DefaultArgon2idParams}
+	// knownHashers проверяют хеши, созданные прежними алгоритмами
+	knownHashers = []PasswordHasher{&Argon2idHasher{Params: DefaultArgon2idParams}, NewBcryptHasher(bcrypt.DefaultCost)}
+)
+
+// SetPasswordHasher задает хешер для новых паролей. По умолчанию используется Argon2id
+// с DefaultArgon2idParams. Хеши других алгоритмов по-прежнему проверяются CheckPassword
+func SetPasswordHasher(h PasswordHasher) {
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	defaultHasher = h
+}
+
+// DefaultPasswordHasher возвращает хешер для новых паролей
+func DefaultPasswordHasher() PasswordHasher {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	return defaultHasher
+}
+
+// hasherFor находит хешер, которым создан hash
+func hasherFor(hash string) (PasswordHasher, error) {
+	if h := DefaultPasswordHasher(); h.Supports(hash) {
+		return h, nil
+	}
+	for _, h := range knownHashers {
+		if h.Supports(hash) {
+			return h, nil
+		}
+	}
+	return nil, ErrUnknownHashFormat
+}
+
+// NeedsRehash сообщает, что хеш нужно пересчитать хешером по умолчанию,
+// например после перехода с bcrypt на Argon2id или изменения параметров
+func NeedsRehash(hash string) bool {
+	return DefaultPasswordHasher().NeedsRehash(hash)
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+// testArgon2idParams — параметры с малой стоимостью, чтобы тесты выполнялись быстро
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHashVerify(t *testing.T) {
+	h, err := NewArgon2idHasher(testArgon2idParams)
+	if err != nil {
+		t.Fatalf("NewArgon2idHasher: %v", err)
+	}
+	hash, err := h.Hash("secret-password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if err := h.Verify(hash, "secret-password"); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := h.Verify(hash, "wrong-password"); !errors.Is(err, ErrMismatchedPassword) {
+		t.Errorf("неверный пароль: ожидалась ErrMismatchedPassword, получено %v", err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("NeedsRehash для хеша с текущими параметрами")
+	}
+}
+
+func TestArgon2idVerifyRejectsBadParams(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"пустой хеш", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdHNhbHQ$"},
+		{"пустая соль", "$argon2id$v=19$m=64,t=1,p=1$$c2FsdHNhbHQ"},
+		{"нулевое число итераций", "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$c2FsdHNhbHQ"},
+		{"нулевое число потоков", "$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHQ$c2FsdHNhbHQ"},
+		{"нулевой объем памяти", "$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$c2FsdHNhbHQ"},
+		{"слишком большой объем памяти", "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$c2FsdHNhbHQ"},
+		{"другая версия", "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$c2FsdHNhbHQ"},
+		{"не хватает частей", "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ"},
+	}
+	h := &Argon2idHasher{Params: testArgon2idParams}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := h.Verify(tt.hash, "secret-password"); !errors.Is(err, ErrUnknownHashFormat) {
+				t.Errorf("ожидалась ErrUnknownHashFormat, получено %v", err)
+			}
+			if !h.NeedsRehash(tt.hash) {
+				t.Error("NeedsRehash для некорректного хеша")
+			}
+		})
+	}
+}
+
+func TestNewArgon2idHasherRejectsBadParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *Argon2idParams)
+	}{
+		{"нулевые параметры", func(p *Argon2idParams) { *p = Argon2idParams{} }},
+		{"нулевое число итераций", func(p *Argon2idParams) { p.Iterations = 0 }},
+		{"нулевое число потоков", func(p *Argon2idParams) { p.Parallelism = 0 }},
+		{"пустая соль", func(p *Argon2idParams) { p.SaltLength = 0 }},
+		{"пустой хеш", func(p *Argon2idParams) { p.KeyLength = 0 }},
+		{"слишком большой объем памяти", func(p *Argon2idParams) { p.Memory = MaxArgon2idMemory + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testArgon2idParams
+			tt.modify(&params)
+			if _, err := NewArgon2idHasher(params); err == nil {
+				t.Error("параметры приняты")
+			}
+			if _, err := (&Argon2idHasher{Params: params}).Hash("secret-password"); err == nil {
+				t.Error("Hash с недопустимыми параметрами")
+			}
+		})
+	}
+}
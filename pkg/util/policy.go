@@ -0,0 +1,314 @@
+package util
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды нарушений парольной политики. Не меняются между версиями и подходят для локализации в UI
+const (
+	ViolationTooShort          = "too_short"
+	ViolationTooLong           = "too_long"
+	ViolationCharClasses       = "char_classes"
+	ViolationBreached          = "breached"
+	ViolationSimilarToUsername = "similar_to_username"
+)
+
+// PolicyViolation — одно нарушение парольной политики
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError содержит все нарушения парольной политики
+type PasswordPolicyError struct {
+	Violations []PolicyViolation `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "пароль не соответствует политике: " + strings.Join(messages, "; ")
+}
+
+// Has сообщает, что среди нарушений есть нарушение с кодом code
+func (e *PasswordPolicyError) Has(code string) bool {
+	for _, v := range e.Violations {
+		if v.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// BreachedChecker проверяет пароль по списку утекших паролей
+type BreachedChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// PasswordPolicy задает требования к паролям. Нулевое значение поля отключает проверку
+type PasswordPolicy struct {
+	MinLength      int // Минимальная длина в символах
+	MaxBytes       int // Максимальная длина в байтах. bcrypt не принимает пароли длиннее 72 байт
+	MinCharClasses int // Минимум классов символов из четырех: строчные, прописные, цифры, прочие
+
+	// MaxUsernameSimilarity — максимально допустимое сходство пароля с именем пользователя от 0 до 1.
+	// Пароль, содержащий имя пользователя целиком, отклоняется при любом ненулевом значении
+	MaxUsernameSimilarity float64
+
+	Breached BreachedChecker // Список утекших паролей. nil — не проверять
+}
+
+// DefaultPasswordPolicy — политика по умолчанию в духе NIST SP 800-63B:
+// длина важнее состава, пароль не должен повторять имя пользователя
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:             10,
+	MaxBytes:              72,
+	MinCharClasses:        2,
+	MaxUsernameSimilarity: 0.7,
+}
+
+var (
+	policyMu      sync.RWMutex
+	currentPolicy = DefaultPasswordPolicy
+)
+
+// SetPasswordPolicy задает политику, которую CheckPasswordPolicy применяет
+// при создании учетных записей и смене пароля
+func SetPasswordPolicy(p PasswordPolicy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	currentPolicy = p
+}
+
+// CheckPasswordPolicy проверяет пароль по текущей политике (см. SetPasswordPolicy)
+func CheckPasswordPolicy(password, username string) error {
+	policyMu.RLock()
+	p := currentPolicy
+	policyMu.RUnlock()
+	return p.Check(password, username)
+}
+
+// Check проверяет пароль пользователя username и возвращает *PasswordPolicyError со всеми нарушениями.
+// Ошибка чтения списка утекших паролей возвращается как есть
+func (p PasswordPolicy) Check(password, username string) error {
+	var violations []PolicyViolation
+	add := func(code, format string, args ...interface{}) {
+		violations = append(violations, PolicyViolation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		add(ViolationTooShort, "пароль должен содержать не менее %d символов", p.MinLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		add(ViolationTooLong, "пароль должен занимать не более %d байт", p.MaxBytes)
+	}
+	if p.MinCharClasses > 0 && charClasses(password) < p.MinCharClasses {
+		add(ViolationCharClasses, "пароль должен содержать символы не менее %d классов: строчные и прописные буквы, цифры, прочие символы", p.MinCharClasses)
+	}
+	if p.MaxUsernameSimilarity > 0 && username != "" && similarToUsername(password, username, p.MaxUsernameSimilarity) {
+		add(ViolationSimilarToUsername, "пароль не должен совпадать с именем пользователя или содержать его")
+	}
+	if p.Breached != nil && password != "" {
+		breached, err := p.Breached.IsBreached(password)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки пароля по списку утекших: %w", err)
+		}
+		if breached {
+			add(ViolationBreached, "пароль встречается в утечках, выберите другой")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// charClasses возвращает количество классов символов в пароле
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// similarToUsername сравнивает пароль и имя пользователя без учета регистра:
+// вхождение одного в другое, вхождение имени, записанного задом наперед,
+// или сходство по расстоянию Левенштейна выше порога
+func similarToUsername(password, username string, threshold float64) bool {
+	pw := []rune(strings.ToLower(password))
+	un := []rune(strings.ToLower(username))
+	if len(pw) == 0 || len(un) == 0 {
+		return false
+	}
+	if strings.Contains(string(pw), string(un)) || strings.Contains(string(un), string(pw)) ||
+		strings.Contains(string(pw), string(reverse(un))) {
+		return true
+	}
+
+	longest := len(pw)
+	if len(un) > longest {
+		longest = len(un)
+	}
+	similarity := 1 - float64(levenshtein(pw, un))/float64(longest)
+	return similarity > threshold
+}
+
+// reverse возвращает символы s в обратном порядке
+func reverse(s []rune) []rune {
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[len(s)-1-i] = c
+	}
+	return r
+}
+
+// levenshtein возвращает расстояние редактирования между a и b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// BreachedRangeDir проверяет пароли по локальной копии базы Pwned Passwords в формате
+// k-анонимности: файл <dir>/<первые 5 символов SHA-1> содержит строки "<остальные 35 символов>:<количество>".
+// Так раскладывает базу официальная утилита загрузки, и при проверке читается только один файл диапазона
+type BreachedRangeDir struct {
+	Dir string
+}
+
+// NewBreachedRangeDir создает проверку по каталогу файлов диапазонов
+func NewBreachedRangeDir(dir string) (*BreachedRangeDir, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s не является каталогом", dir)
+	}
+	return &BreachedRangeDir{Dir: dir}, nil
+}
+
+func (b *BreachedRangeDir) IsBreached(password string) (bool, error) {
+	prefix, suffix := sha1Range(password)
+
+	f, err := os.Open(filepath.Join(b.Dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		// Нет файла диапазона — в базе нет хешей с таким префиксом
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if s, _, _ := strings.Cut(scanner.Text(), ":"); strings.EqualFold(strings.TrimSpace(s), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// BreachedHashList — список утекших паролей в памяти, сгруппированный по префиксам SHA-1.
+// Подходит для небольших списков (например, самых распространенных паролей)
+type BreachedHashList struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedHashFile загружает файл со строками "<SHA-1>" или "<SHA-1>:<количество>".
+// Пустые строки и строки, начинающиеся с '#', пропускаются
+func LoadBreachedHashFile(path string) (*BreachedHashList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := &BreachedHashList{ranges: map[string]map[string]struct{}{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%s:%d: некорректный хеш SHA-1", path, line)
+		}
+		list.add(hash[:5], hash[5:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (l *BreachedHashList) add(prefix, suffix string) {
+	r, ok := l.ranges[prefix]
+	if !ok {
+		r = map[string]struct{}{}
+		l.ranges[prefix] = r
+	}
+	r[suffix] = struct{}{}
+}
+
+func (l *BreachedHashList) IsBreached(password string) (bool, error) {
+	prefix, suffix := sha1Range(password)
+	_, ok := l.ranges[prefix][suffix]
+	return ok, nil
+}
+
+// sha1Range возвращает префикс из 5 символов и остаток SHA-1 пароля в верхнем регистре
+func sha1Range(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:5], hash[5:]
+}
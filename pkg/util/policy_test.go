@@ -0,0 +1,149 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSHA1Range(t *testing.T) {
+	prefix, suffix := sha1Range("password")
+	if prefix != "5BAA6" || suffix != "1E4C9B93F3F0682250B6CF8331B7EE68FD8" {
+		t.Errorf("sha1Range = %s, %s", prefix, suffix)
+	}
+}
+
+func TestBreachedRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	_, suffix := sha1Range("password")
+	// Строки с другим регистром, пробелами и CRLF, как в выгрузках Pwned Passwords
+	ranges := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n  " + strings.ToLower(suffix) + " :3861493\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6"), []byte(ranges), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	prefix, _ := sha1Range("correct horse battery staple")
+	if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	checker, err := NewBreachedRangeDir(dir)
+	if err != nil {
+		t.Fatalf("NewBreachedRangeDir: %v", err)
+	}
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"хеш в файле диапазона", "password", true},
+		{"хеша нет в файле диапазона", "Password", false},
+		{"файл диапазона .txt без хеша", "correct horse battery staple", false},
+		{"нет файла диапазона", "no-such-range-file-0123456789", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checker.IsBreached(tt.password)
+			if err != nil || got != tt.want {
+				t.Errorf("IsBreached(%q) = %v, %v, ожидалось %v", tt.password, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewBreachedRangeDir(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("NewBreachedRangeDir несуществующего каталога: %v", err)
+	}
+	if _, err := NewBreachedRangeDir(filepath.Join(dir, "5BAA6")); err == nil {
+		t.Error("NewBreachedRangeDir принял файл вместо каталога")
+	}
+}
+
+func TestLoadBreachedHashFile(t *testing.T) {
+	prefix, suffix := sha1Range("password")
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+	data := "# самые распространенные пароли\n\n  " + strings.ToLower(prefix+suffix) + ":3861493  \n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedHashFile(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedHashFile: %v", err)
+	}
+	for password, want := range map[string]bool{"password": true, "Password": false} {
+		if got, err := list.IsBreached(password); err != nil || got != want {
+			t.Errorf("IsBreached(%q) = %v, %v, ожидалось %v", password, got, err, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("not-a-hash\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBreachedHashFile(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("некорректный хеш: ожидалась ошибка с номером строки, получено %v", err)
+	}
+	if _, err := LoadBreachedHashFile(filepath.Join(t.TempDir(), "missing.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("несуществующий файл: %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abc", "abc", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"ivanov", "ivanova", 1},
+		{"пароль", "пороль", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, ожидалось %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarToUsername(t *testing.T) {
+	tests := []struct {
+		password, username string
+		want               bool
+	}{
+		{"ivan.petrov", "ivan.petrov", true},
+		{"Ivan.Petrov2024!", "ivan.petrov", true},
+		{"my-IVAN.PETROV-pass", "ivan.petrov", true},
+		{"vortep.navi", "ivan.petrov", true},
+		{"xVORTEP.NAVIx", "ivan.petrov", true},
+		{"ivan.petrob", "ivan.petrov", true},
+		{"petrov", "ivan.petrov", true},
+		{"correct horse battery", "ivan.petrov", false},
+		{"", "ivan.petrov", false},
+		{"something", "", false},
+	}
+	for _, tt := range tests {
+		if got := similarToUsername(tt.password, tt.username, DefaultPasswordPolicy.MaxUsernameSimilarity); got != tt.want {
+			t.Errorf("similarToUsername(%q, %q) = %v, ожидалось %v", tt.password, tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	prefix, suffix := sha1Range("Breached-Password-1")
+	list := &BreachedHashList{ranges: map[string]map[string]struct{}{}}
+	list.add(prefix, suffix)
+	policy := DefaultPasswordPolicy
+	policy.Breached = list
+
+	var perr *PasswordPolicyError
+	if err := policy.Check("Breached-Password-1", "ivan.petrov"); !errors.As(err, &perr) || !perr.Has(ViolationBreached) {
+		t.Errorf("Check утекшего пароля = %v, ожидалось нарушение %s", err, ViolationBreached)
+	}
+	if err := policy.Check("Unique-Password-2", "ivan.petrov"); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
@@ -1,19 +1,17 @@
 package util
 
-import "golang.org/x/crypto/bcrypt"
-
+// HashPassword хеширует пароль хешером по умолчанию (Argon2id, см. SetPasswordHasher)
 func HashPassword(password string) (string, error) {
-	// Используем bcrypt для хеширования пароля
-	// bcrypt.DefaultCost задает стандартную сложность хеширования
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	// Возвращаем хеш в строковом виде
-	return string(hashedPassword), nil
+	return DefaultPasswordHasher().Hash(password)
 }
 
-// Функция для проверки пароля
+// CheckPassword проверяет пароль по хешу. Алгоритм определяется по формату хеша,
+// поэтому пароли, захешированные bcrypt до перехода на Argon2id, продолжают проверяться.
+// Возвращает ErrMismatchedPassword, если пароль неверен
 func CheckPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-}
\ No newline at end of file
+	h, err := hasherFor(hashedPassword)
+	if err != nil {
+		return err
+	}
+	return h.Verify(hashedPassword, password)
+}